@@ -0,0 +1,201 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for a datacenter which was skipped because its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open, datacenter skipped")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request pass, failures are counted.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every request until the cool-down has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request pass, its outcome decides if the breaker closes or opens again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig configures the circuit breakers of a Transporter.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures after which a breaker opens.
+	FailureThreshold int
+	// CoolDown is the time an open breaker rejects requests before a trial request is let through.
+	CoolDown time.Duration
+	// OnStateChange (optional) is called whenever the breaker of a server changes its state.
+	OnStateChange func(server string, from BreakerState, to BreakerState)
+}
+
+// DefaultBreakerConfig returns the breaker settings used by NewTransporter.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 3,
+		CoolDown:         30 * time.Second,
+	}
+}
+
+// CircuitBreaker tracks the health of a single datacenter.
+// Note: To create a new instance of CircuitBreaker, use the NewCircuitBreaker function.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	server   string
+	config   BreakerConfig
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker for the given server.
+func NewCircuitBreaker(server string, config BreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultBreakerConfig().FailureThreshold
+	}
+	return &CircuitBreaker{
+		server: server,
+		config: config,
+		state:  BreakerClosed,
+		now:    time.Now,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.config.CoolDown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a request may be sent to the server. An open breaker turns half-open
+// once the cool-down has passed and then allows exactly one trial request.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	from := b.state
+	allowed := true
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.config.CoolDown {
+			allowed = false
+			break
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+	case BreakerHalfOpen:
+		if b.trial {
+			allowed = false
+			break
+		}
+		b.trial = true
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return allowed
+}
+
+// Success records a successful request and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	from := b.state
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trial = false
+	b.mu.Unlock()
+
+	b.notify(from, BreakerClosed)
+}
+
+// Failure records a failed request. The breaker opens when the failure threshold is reached
+// or when the trial request of a half-open breaker failed.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	b.trial = false
+	if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// Release records a request whose outcome says nothing about the server, e.g. one cancelled by
+// the caller. It isn't counted, and a half-open breaker allows another trial request.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+func (b *CircuitBreaker) notify(from BreakerState, to BreakerState) {
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.server, from, to)
+	}
+}
+
+// BreakerGroup holds one CircuitBreaker per server, breakers are created on first use.
+// Note: To create a new instance of BreakerGroup, use the NewBreakerGroup function.
+type BreakerGroup struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	breakers map[string]*CircuitBreaker
+}
+
+// NewBreakerGroup creates an empty BreakerGroup whose breakers use the given config.
+func NewBreakerGroup(config BreakerConfig) *BreakerGroup {
+	return &BreakerGroup{
+		config:   config,
+		breakers: map[string]*CircuitBreaker{},
+	}
+}
+
+// Get returns the breaker of the given server.
+func (g *BreakerGroup) Get(server string) *CircuitBreaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.breakers[server]
+	if !ok {
+		b = NewCircuitBreaker(server, g.config)
+		g.breakers[server] = b
+	}
+	return b
+}
+
+// isDatacenterFailure reports whether a response counts as a failure of the datacenter itself.
+// Client errors like 404 are valid answers and keep the breaker closed.
+func isDatacenterFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
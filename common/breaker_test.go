@@ -0,0 +1,126 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker("dc1", BreakerConfig{FailureThreshold: 2, CoolDown: time.Minute})
+	b.Failure()
+	if b.State() != BreakerClosed {
+		t.Errorf("breaker should still be closed, got %s", b.State())
+	}
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Errorf("breaker should be open, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Errorf("open breaker shouldn't allow requests")
+	}
+}
+
+func TestCircuitBreakerHalfOpenTrial(t *testing.T) {
+	now := time.Now()
+	var changes []BreakerState
+	b := NewCircuitBreaker("dc1", BreakerConfig{
+		FailureThreshold: 1,
+		CoolDown:         time.Second,
+		OnStateChange: func(server string, from BreakerState, to BreakerState) {
+			changes = append(changes, to)
+		},
+	})
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	now = now.Add(2 * time.Second)
+	if !b.Allow() {
+		t.Fatalf("breaker should allow a trial request after the cool-down")
+	}
+	if b.Allow() {
+		t.Errorf("only one trial request should be allowed while half-open")
+	}
+	b.Failure()
+	if b.State() != BreakerOpen {
+		t.Errorf("failed trial should open the breaker again, got %s", b.State())
+	}
+	now = now.Add(2 * time.Second)
+	b.Allow()
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Errorf("successful trial should close the breaker, got %s", b.State())
+	}
+
+	expected := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(changes) != len(expected) {
+		t.Fatalf("unexpected state changes: %v", changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("state change %d: expected %s, got %s", i, expected[i], changes[i])
+		}
+	}
+}
+
+func TestDatacenterFetchSkipsOpenBreaker(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/broken/jobs" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ts := createTransporter()
+	ts.Breakers = NewBreakerGroup(BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+	servers := []string{server.URL + "/broken", server.URL + "/healthy"}
+
+	ts.DoDatacenterFetch(servers, "", "", nil, "/jobs", http.MethodGet)
+	results := ts.FetchDatacenters(context.Background(), DatacenterRequest{Servers: servers, Resource: "/jobs", Method: http.MethodGet})
+
+	if !errors.Is(results[0].Err, ErrCircuitOpen) {
+		t.Errorf("broken datacenter should be skipped, got %v", results[0].Err)
+	}
+	if results[1].Err != nil || results[1].Response.StatusCode != http.StatusOK {
+		t.Errorf("healthy datacenter should be reached, got %v", results[1].Err)
+	}
+	if requests["/broken/jobs"] != 1 {
+		t.Errorf("broken datacenter should be requested once, got %d", requests["/broken/jobs"])
+	}
+}
+
+func TestDatacenterFetchIgnoresCancellation(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ts := createTransporter()
+	ts.Breakers = NewBreakerGroup(BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	results := ts.FetchDatacenters(ctx, DatacenterRequest{Servers: []string{server.URL}, Method: http.MethodGet})
+
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Fatalf("expected the request to be cancelled, got %v", results[0].Err)
+	}
+	if state := ts.Breakers.Get(server.URL).State(); state != BreakerClosed {
+		t.Errorf("a cancelled request must not open the breaker, got %s", state)
+	}
+}
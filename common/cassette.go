@@ -0,0 +1,204 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// cassetteKeep are the keys whose values are recorded as is, job IDs are needed to replay the
+// requests which follow a send.
+var cassetteKeep = map[string]bool{"jobid": true, "jobids": true}
+
+// CassetteMode selects whether a Cassette records or replays requests.
+type CassetteMode int
+
+const (
+	// Record sends requests to the real service and records them.
+	Record CassetteMode = iota
+	// Replay answers requests from the recorded interactions without network access.
+	Replay
+)
+
+// Interaction is a recorded request with its response.
+type Interaction struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	RequestBody  string      `json:"requestBody,omitempty"`
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"responseBody,omitempty"`
+}
+
+// Cassette is an http.RoundTripper which records request/response pairs to a file and replays
+// them later, e.g. to run integration tests against a session captured once from the sandbox.
+// The Authorization header is never recorded, phone numbers, message texts and document data are
+// redacted like in logs, and Secrets are replaced in URLs and bodies.
+//
+// Interactions are replayed in recorded order per method and URL, so the parallel requests to
+// several datacenters are answered deterministically.
+//
+//	cassette, err := common.NewCassette("testdata/bulk.json", common.Replay, nil)
+//	client.Transporter.HTTPClient.Transport = cassette
+//
+// Note: To create a new instance of Cassette, use the NewCassette function.
+type Cassette struct {
+	// Secrets maps secret values, e.g. the customer number, to the placeholders written in their
+	// place. In replay mode the placeholders have to be used in the client config.
+	Secrets map[string]string
+
+	path         string
+	mode         CassetteMode
+	next         http.RoundTripper
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewCassette creates a Cassette for the given file. In Record mode requests are sent with next,
+// or http.DefaultTransport if next is nil, and the file is written by Save. In Replay mode the
+// file is read immediately.
+func NewCassette(path string, mode CassetteMode, next http.RoundTripper) (*Cassette, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := &Cassette{
+		Secrets: map[string]string{},
+		path:    path,
+		mode:    mode,
+		next:    next,
+	}
+	if mode == Replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+	}
+	return c, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if c.mode == Replay {
+		return c.replay(req, body)
+	}
+	return c.record(req, body)
+}
+
+func (c *Cassette) record(req *http.Request, body []byte) (*http.Response, error) {
+	res, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	header := http.Header{}
+	if ct := res.Header.Get("Content-Type"); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, Interaction{
+		Method:       req.Method,
+		URL:          c.scrub(req.URL.String()),
+		RequestBody:  c.scrubBody(body),
+		StatusCode:   res.StatusCode,
+		Header:       header,
+		ResponseBody: c.scrubBody(resBody),
+	})
+	return res, nil
+}
+
+// replay answers with the first unused interaction for the same method and URL, preferring one
+// with the same request body.
+func (c *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	url := req.URL.String()
+	reqBody := c.scrubBody(body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	match := -1
+	for i, in := range c.interactions {
+		if c.used[i] || in.Method != req.Method || in.URL != url {
+			continue
+		}
+		if in.RequestBody == reqBody {
+			match = i
+			break
+		}
+		if match == -1 {
+			match = i
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("cassette %s has no recorded interaction for %s %s", c.path, req.Method, url)
+	}
+	c.used[match] = true
+
+	in := c.interactions[match]
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(in.ResponseBody)),
+		ContentLength: int64(len(in.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded interactions to the cassette file.
+func (c *Cassette) Save() error {
+	if c.mode != Record {
+		return nil
+	}
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.interactions, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+func (c *Cassette) scrub(s string) string {
+	for secret, placeholder := range c.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, placeholder)
+		}
+	}
+	return s
+}
+
+func (c *Cassette) scrubBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	return c.scrub(string(redact(body, cassetteKeep)))
+}
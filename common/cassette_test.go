@@ -0,0 +1,94 @@
+package common
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	servers := []string{}
+	for _, name := range []string{"dc1", "dc2"} {
+		name := name
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls[name]++
+			call := calls[name]
+			mu.Unlock()
+			if name == "dc1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"jobId":"J00000001","number":"+4917600000000","call":%d}`, call)
+		}))
+		defer server.Close()
+		servers = append(servers, server.URL+"/4711")
+	}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := NewCassette(path, Record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Secrets["4711"] = "12345"
+	ts := createTransporter()
+	ts.HTTPClient.Transport = recorder
+	body := []byte(`{"jobIds":["J00000001"],"dst":"+4917600000000"}`)
+	for i := 0; i < 2; i++ {
+		ts.DoDatacenterFetch(servers, "user", "password", body, "/reports", http.MethodPost)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, _ := os.ReadFile(path)
+	for _, leak := range []string{"4711", "+4917600000000", base64.StdEncoding.EncodeToString([]byte("user:password"))} {
+		if strings.Contains(string(recorded), leak) {
+			t.Errorf("cassette contains %q: %s", leak, recorded)
+		}
+	}
+	if !strings.Contains(string(recorded), "J00000001") {
+		t.Errorf("cassette lost the job ID: %s", recorded)
+	}
+
+	player, err := NewCassette(path, Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts = createTransporter()
+	ts.HTTPClient.Transport = player
+	for i := range servers {
+		servers[i] = strings.Replace(servers[i], "4711", "12345", 1)
+	}
+	for i := 1; i <= 2; i++ {
+		res := ts.DoDatacenterFetch(servers, "other", "secret", body, "/reports", http.MethodPost)
+		if len(res) != 2 {
+			t.Fatalf("expected 2 replayed responses, got %d", len(res))
+		}
+		if res[0].StatusCode != http.StatusNotFound || res[1].StatusCode != http.StatusOK {
+			t.Errorf("responses replayed to the wrong datacenters: %d %d", res[0].StatusCode, res[1].StatusCode)
+		}
+		data, _ := io.ReadAll(res[1].Body)
+		if !strings.Contains(string(data), fmt.Sprintf(`"call":%d`, i)) {
+			t.Errorf("responses replayed out of order, request %d got %s", i, data)
+		}
+	}
+	if calls["dc1"] != 2 || calls["dc2"] != 2 {
+		t.Errorf("replay shouldn't reach the servers, got %v", calls)
+	}
+
+	res := ts.DoDatacenterFetch(servers[:1], "other", "secret", body, "/reports", http.MethodPost)
+	if len(res) != 0 {
+		t.Errorf("unrecorded request should fail, got %d responses", len(res))
+	}
+}
@@ -0,0 +1,311 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIdempotencyTTL is how long the job ID of an idempotency key is kept.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyLease is how long a claimed key without job ID blocks other sends with the
+	// same key, e.g. after the process died during the send. It matches the 10 minutes of the SMS
+	// duplicate detection.
+	DefaultIdempotencyLease = 10 * time.Minute
+)
+
+var (
+	// ErrIdempotencyKeyInUse is returned by IdempotencyStore.Claim if another send with the same
+	// key is in progress.
+	ErrIdempotencyKeyInUse = errors.New("idempotency key is used by a send in progress")
+	// ErrNoIdempotencyStore is returned by the clients for a send with an idempotency key if the
+	// client has no IdempotencyStore.
+	ErrNoIdempotencyStore = errors.New("idempotency key given, but the client has no idempotency store")
+)
+
+// IdempotencyStore maps the idempotency keys of sends to the IDs of the jobs they created, so a
+// retried send returns the job ID of the first one instead of sending the job again. A store
+// shared by several processes, e.g. in Redis or SQL, must claim keys atomically.
+type IdempotencyStore interface {
+	// Claim reserves key for a send. If a send with key already created a job, its job ID is
+	// returned and the key stays as it is. If a send with key is in progress, the error is
+	// ErrIdempotencyKeyInUse. Otherwise jobID is empty and the caller has to Complete or Release
+	// the key.
+	Claim(ctx context.Context, key string) (jobID string, err error)
+	// Complete stores the job ID of a claimed key.
+	Complete(ctx context.Context, key string, jobID string) error
+	// Release removes the claim of a key whose send was rejected, so it can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyRecord is the state of an idempotency key.
+type IdempotencyRecord struct {
+	Key string `json:"key"`
+	// JobID is empty while the send is in progress.
+	JobID   string    `json:"jobId,omitempty"`
+	Claimed time.Time `json:"claimed"`
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore held in memory. Expired keys are dropped when
+// they are claimed again.
+// Note: To create a new instance of MemoryIdempotencyStore, use the NewMemoryIdempotencyStore function.
+type MemoryIdempotencyStore struct {
+	// TTL is how long job IDs are kept, DefaultIdempotencyTTL if zero.
+	TTL time.Duration
+	// Lease is how long claims without job ID are kept, DefaultIdempotencyLease if zero.
+	Lease time.Duration
+
+	now     func() time.Time
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore creates an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{now: time.Now, records: map[string]IdempotencyRecord{}}
+}
+
+// Claim reserves key or returns the job ID stored for it.
+func (s *MemoryIdempotencyStore) Claim(ctx context.Context, key string) (string, error) {
+	_, jobID, err := s.claim(key)
+	return jobID, err
+}
+
+// claim also returns the new record, if key was claimed.
+func (s *MemoryIdempotencyStore) claim(key string) (IdempotencyRecord, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if r, ok := s.records[key]; ok && !s.expired(r, now) {
+		if r.JobID == "" {
+			return IdempotencyRecord{}, "", fmt.Errorf("%w: %s", ErrIdempotencyKeyInUse, key)
+		}
+		return IdempotencyRecord{}, r.JobID, nil
+	}
+	r := IdempotencyRecord{Key: key, Claimed: now}
+	s.records[key] = r
+	return r, "", nil
+}
+
+// Complete stores the job ID of key.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, jobID string) error {
+	s.complete(key, jobID)
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) complete(key string, jobID string) IdempotencyRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.records[key]
+	r.Key, r.JobID = key, jobID
+	if r.Claimed.IsZero() {
+		r.Claimed = s.now()
+	}
+	s.records[key] = r
+	return r
+}
+
+// Release removes the claim of key.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// Records returns the keys which aren't expired.
+func (s *MemoryIdempotencyStore) Records() []IdempotencyRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var records []IdempotencyRecord
+	for _, r := range s.records {
+		if !s.expired(r, now) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// expired reports whether the record of a key can be dropped.
+func (s *MemoryIdempotencyStore) expired(r IdempotencyRecord, now time.Time) bool {
+	if r.JobID == "" {
+		lease := s.Lease
+		if lease == 0 {
+			lease = DefaultIdempotencyLease
+		}
+		return now.Sub(r.Claimed) >= lease
+	}
+	ttl := s.TTL
+	if ttl == 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return now.Sub(r.Claimed) >= ttl
+}
+
+// FileIdempotencyStore is an IdempotencyStore persisted in a file, for a single process. Every
+// change is appended as a JSON line. When the file is opened, expired keys are dropped and the
+// file is rewritten.
+// Note: To create a new instance of FileIdempotencyStore, use the OpenFileIdempotencyStore function.
+type FileIdempotencyStore struct {
+	*MemoryIdempotencyStore
+
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// idempotencyLine is a line of the file of a FileIdempotencyStore.
+type idempotencyLine struct {
+	IdempotencyRecord
+	Released bool `json:"released,omitempty"`
+}
+
+// OpenFileIdempotencyStore opens or creates the file of a FileIdempotencyStore with
+// DefaultIdempotencyTTL and DefaultIdempotencyLease.
+func OpenFileIdempotencyStore(path string) (*FileIdempotencyStore, error) {
+	return openFileIdempotencyStore(path, NewMemoryIdempotencyStore())
+}
+
+func openFileIdempotencyStore(path string, memory *MemoryIdempotencyStore) (*FileIdempotencyStore, error) {
+	s := &FileIdempotencyStore{MemoryIdempotencyStore: memory, path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileIdempotencyStore) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var l idempotencyLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		if l.Released {
+			delete(s.records, l.Key)
+		} else {
+			s.records[l.Key] = l.IdempotencyRecord
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file with the keys which aren't expired and opens it for appending.
+func (s *FileIdempotencyStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, r := range s.Records() {
+		if err := enc.Encode(idempotencyLine{IdempotencyRecord: r}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+// Claim reserves key or returns the job ID stored for it.
+func (s *FileIdempotencyStore) Claim(ctx context.Context, key string) (string, error) {
+	r, jobID, err := s.claim(key)
+	if err != nil || jobID != "" {
+		return jobID, err
+	}
+	if err := s.append(idempotencyLine{IdempotencyRecord: r}); err != nil {
+		s.MemoryIdempotencyStore.Release(ctx, key)
+		return "", err
+	}
+	return "", nil
+}
+
+// Complete stores the job ID of key.
+func (s *FileIdempotencyStore) Complete(ctx context.Context, key string, jobID string) error {
+	return s.append(idempotencyLine{IdempotencyRecord: s.complete(key, jobID)})
+}
+
+// Release removes the claim of key.
+func (s *FileIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.MemoryIdempotencyStore.Release(ctx, key)
+	return s.append(idempotencyLine{IdempotencyRecord: IdempotencyRecord{Key: key}, Released: true})
+}
+
+func (s *FileIdempotencyStore) append(l idempotencyLine) error {
+	line, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileIdempotencyStore) Close() error {
+	return s.file.Close()
+}
+
+// SettleIdempotencyKey completes key with jobID after a successful send and returns err, joined
+// with the error of the store. The key is only released after a failed send if rejected is set,
+// i.e. the job certainly wasn't created, e.g. because the send failed before the request or the
+// service answered with a 4xx status. After timeouts and server errors the job may have been
+// created anyway, so the claim is kept until its lease expires. It is meant for senders which
+// claimed key, even if ctx is already canceled.
+func SettleIdempotencyKey(ctx context.Context, store IdempotencyStore, key string, jobID string, err error, rejected bool) error {
+	ctx = context.WithoutCancel(ctx)
+	if err != nil || jobID == "" {
+		if !rejected {
+			return err
+		}
+		if releaseErr := store.Release(ctx, key); releaseErr != nil {
+			return errors.Join(err, fmt.Errorf("release idempotency key %q: %w", key, releaseErr))
+		}
+		return err
+	}
+	if completeErr := store.Complete(ctx, key, jobID); completeErr != nil {
+		return fmt.Errorf("job %s was sent, but its idempotency key %q wasn't stored: %w", jobID, key, completeErr)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryIdempotencyStore()
+	s.now = func() time.Time { return now }
+
+	if jobID, err := s.Claim(ctx, "msg-1"); jobID != "" || err != nil {
+		t.Fatalf("expected a new claim, got %q, %v", jobID, err)
+	}
+	if _, err := s.Claim(ctx, "msg-1"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Fatalf("expected ErrIdempotencyKeyInUse, got %v", err)
+	}
+	if err := s.Complete(ctx, "msg-1", "job-1"); err != nil {
+		t.Fatal(err)
+	}
+	if jobID, err := s.Claim(ctx, "msg-1"); jobID != "job-1" || err != nil {
+		t.Fatalf("expected the stored job ID, got %q, %v", jobID, err)
+	}
+
+	s.Claim(ctx, "msg-2")
+	now = now.Add(DefaultIdempotencyLease)
+	if jobID, err := s.Claim(ctx, "msg-2"); jobID != "" || err != nil {
+		t.Fatalf("expected the claim to expire after the lease, got %q, %v", jobID, err)
+	}
+	s.Release(ctx, "msg-2")
+	if jobID, err := s.Claim(ctx, "msg-2"); jobID != "" || err != nil {
+		t.Fatalf("expected the released key to be claimable, got %q, %v", jobID, err)
+	}
+
+	now = now.Add(DefaultIdempotencyTTL)
+	if jobID, _ := s.Claim(ctx, "msg-1"); jobID != "" {
+		t.Errorf("expected the job ID to expire after the TTL, got %q", jobID)
+	}
+}
+
+func TestFileIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	s, err := OpenFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"sent", "released", "in-flight"} {
+		if _, err := s.Claim(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Complete(ctx, "sent", "job-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release(ctx, "released"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFileIdempotencyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if jobID, err := s.Claim(ctx, "sent"); jobID != "job-1" || err != nil {
+		t.Errorf("expected the stored job ID after reopening, got %q, %v", jobID, err)
+	}
+	if _, err := s.Claim(ctx, "in-flight"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Errorf("expected the send in flight during the restart to stay claimed, got %v", err)
+	}
+	if jobID, err := s.Claim(ctx, "released"); jobID != "" || err != nil {
+		t.Errorf("expected the released key to be claimable, got %q, %v", jobID, err)
+	}
+	if records := s.Records(); len(records) != 3 {
+		t.Errorf("expected 3 records, got %+v", records)
+	}
+}
+
+func TestSettleIdempotencyKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewMemoryIdempotencyStore()
+	sendErr := errors.New("timeout")
+
+	s.Claim(ctx, "rejected")
+	if err := SettleIdempotencyKey(ctx, s, "rejected", "", sendErr, true); err != sendErr {
+		t.Errorf("expected the send error, got %v", err)
+	}
+	if jobID, err := s.Claim(ctx, "rejected"); jobID != "" || err != nil {
+		t.Errorf("expected the key of the rejected send to be released, got %q, %v", jobID, err)
+	}
+
+	s.Claim(ctx, "failed")
+	if err := SettleIdempotencyKey(ctx, s, "failed", "", sendErr, false); err != sendErr {
+		t.Errorf("expected the send error, got %v", err)
+	}
+	if _, err := s.Claim(ctx, "failed"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Errorf("expected the key of the failed send to stay claimed, got %v", err)
+	}
+
+	s.Claim(ctx, "sent")
+	if err := SettleIdempotencyKey(ctx, s, "sent", "job-1", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if jobID, _ := s.Claim(ctx, "sent"); jobID != "job-1" {
+		t.Errorf("expected the job ID to be stored, got %q", jobID)
+	}
+}
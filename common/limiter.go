@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// OperationClass groups the calls of a client for rate limiting, so that e.g. report polling
+// can't starve sending.
+type OperationClass string
+
+const (
+	// SendOperation covers calls which submit new jobs.
+	SendOperation OperationClass = "send"
+	// ReportOperation covers calls which query or delete reports.
+	ReportOperation OperationClass = "report"
+)
+
+// Limiter combines a token bucket rate limiter with a limit for requests in flight.
+// A Limiter can be shared between several clients to enforce a limit for a whole account.
+// Note: To create a new instance of Limiter, use the NewLimiter function.
+type Limiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	inFlight chan struct{}
+	now      func() time.Time
+}
+
+// NewLimiter creates a Limiter which allows ratePerSecond requests per second with bursts of up to
+// burst requests, and at most maxInFlight concurrent requests. A ratePerSecond or maxInFlight of
+// zero or below disables the respective limit.
+func NewLimiter(ratePerSecond float64, burst int, maxInFlight int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &Limiter{
+		rate:   ratePerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+	l.last = l.now()
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// Acquire blocks until a request may be sent or ctx is done. The returned release function must
+// be called once the request has finished.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release = func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}
+
+	if err := l.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *Limiter) wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return ctx.Err()
+	}
+	for {
+		l.mu.Lock()
+		now := l.now()
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
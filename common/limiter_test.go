@@ -0,0 +1,78 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	l := NewLimiter(1, 2, 0)
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("burst request %d shouldn't block: %s", i, err)
+		}
+		release()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("request above the burst should block until the deadline, got %v", err)
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	l := NewLimiter(0, 0, 1)
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second request should wait for the first one, got %v", err)
+	}
+
+	release()
+	release, err = l.Acquire(context.Background())
+	if err != nil {
+		t.Errorf("request should pass after release: %s", err)
+	}
+	release()
+}
+
+func TestTransporterUsesLimiterPerClass(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ts := createTransporter()
+	reports := NewLimiter(0, 0, 1)
+	ts.SetLimiter(ReportOperation, reports)
+	blocked, err := reports.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocked()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ts.DoDatacenterFetchContext(ctx, DatacenterRequest{Servers: []string{server.URL}, Method: http.MethodGet})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("report request should wait for its limiter, got %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+	res, err := ts.Do(context.Background(), Call{Class: SendOperation}, req)
+	if err != nil {
+		t.Fatalf("send request shouldn't be limited by the report limiter: %s", err)
+	}
+	res.Body.Close()
+}
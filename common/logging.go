@@ -0,0 +1,154 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Log levels used by the Transporter. Summaries and bodies are logged below slog.LevelInfo, so they
+// only show up if the handler of the logger is configured for them.
+const (
+	// LevelSummary logs one line per request with service, operation, datacenter, status and duration.
+	LevelSummary = slog.LevelDebug
+	// LevelBody additionally logs the request and response bodies.
+	LevelBody = slog.LevelDebug - 4
+	// LevelTransportError logs requests which failed without a response, e.g. on timeouts.
+	LevelTransportError = slog.LevelWarn
+)
+
+// Redacted replaces values which must not show up in logs or recordings.
+const Redacted = "[REDACTED]"
+
+// redactedKeys are the JSON keys of the SMS and fax API whose values are always redacted:
+// phone numbers, message texts, document data and credentials.
+var redactedKeys = map[string]bool{
+	"dst":                true,
+	"src":                true,
+	"number":             true,
+	"alternativenumbers": true,
+	"senttonumber":       true,
+	"remotecsid":         true,
+	"text":               true,
+	"data":               true,
+	"value":              true,
+	"credentials":        true,
+	"password":           true,
+}
+
+// phoneNumber matches international phone numbers in free text, e.g. in customer references or
+// error messages. They have to start with + or 00, so dates and timestamps are kept.
+var phoneNumber = regexp.MustCompile(`(?:\+|\b00)\d[\d ()/-]{5,}\d`)
+
+// Redact returns a copy of a request or response body with phone numbers, message texts, document
+// data and credentials replaced by Redacted. Bodies which aren't JSON are scanned for phone numbers.
+func Redact(body []byte) []byte {
+	return redact(body, nil)
+}
+
+// redact works like Redact, but leaves the values of the keep keys untouched, e.g. job IDs which
+// look like phone numbers.
+func redact(body []byte, keep map[string]bool) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return phoneNumber.ReplaceAll(body, []byte(Redacted))
+	}
+	redacted, err := json.Marshal(redactValue(v, keep))
+	if err != nil {
+		return []byte(Redacted)
+	}
+	return redacted
+}
+
+func redactValue(v any, keep map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			switch key := strings.ToLower(k); {
+			case redactedKeys[key]:
+				v[k] = Redacted
+			case !keep[key]:
+				v[k] = redactValue(val, keep)
+			}
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i], keep)
+		}
+		return v
+	case string:
+		return phoneNumber.ReplaceAllString(v, Redacted)
+	}
+	return v
+}
+
+// logRequest logs a finished request to the configured Logger, if any.
+func (t *Transporter) logRequest(ctx context.Context, call Call, datacenter string, req *http.Request, started time.Time, res *http.Response, err error) {
+	if t.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("service", call.Service),
+		slog.String("operation", call.Operation),
+		slog.String("datacenter", datacenter),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", time.Since(started)),
+	}
+	if err != nil {
+		t.Logger.LogAttrs(ctx, LevelTransportError, "retarus request failed", append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	attrs = append(attrs, slog.Int("status", res.StatusCode))
+	if t.Logger.Enabled(ctx, LevelBody) {
+		attrs = append(attrs,
+			slog.String("request_body", t.logBody(requestBody(req))),
+			slog.String("response_body", t.logBody(responseBody(res))),
+		)
+		if t.LogUnredacted {
+			attrs = append(attrs, slog.String("authorization", req.Header.Get("Authorization")))
+		}
+		t.Logger.LogAttrs(ctx, LevelBody, "retarus request", attrs...)
+		return
+	}
+	t.Logger.LogAttrs(ctx, LevelSummary, "retarus request", attrs...)
+}
+
+func (t *Transporter) logBody(body []byte) string {
+	if t.LogUnredacted || len(body) == 0 {
+		return string(body)
+	}
+	return string(Redact(body))
+}
+
+// requestBody returns a copy of the request body, if the request can provide one.
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	b, _ := io.ReadAll(body)
+	return b
+}
+
+// responseBody reads the response body and replaces it with a copy, so it can still be decoded.
+func responseBody(res *http.Response) []byte {
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	body := `{"messages":[{"text":"secret text","recipients":[{"dst":"+4917600000000","customerRef":"+49 176 000 0000"}]}],` +
+		`"documents":[{"name":"a.pdf","data":"JVBERi0xLjQK"}],"jobId":"J1"}`
+	redacted := string(Redact([]byte(body)))
+	for _, leak := range []string{"secret text", "+4917600000000", "176 000", "JVBERi0xLjQK"} {
+		if strings.Contains(redacted, leak) {
+			t.Errorf("redacted body contains %q: %s", leak, redacted)
+		}
+	}
+	if !strings.Contains(redacted, `"jobId":"J1"`) || !strings.Contains(redacted, `"name":"a.pdf"`) {
+		t.Errorf("redacted body lost harmless values: %s", redacted)
+	}
+
+	text := string(Redact([]byte("invalid number 0049 176 0000000 given")))
+	if text != "invalid number "+Redacted+" given" {
+		t.Errorf("unexpected redaction of plain text: %s", text)
+	}
+	dates := `{"ts":"2024-01-01T12:00:00+01:00","day":"2024-01-01","ref":"call +49 (89) 123-456"}`
+	if redacted := string(Redact([]byte(dates))); !strings.Contains(redacted, `"2024-01-01T12:00:00+01:00"`) || !strings.Contains(redacted, `"day":"2024-01-01"`) || !strings.Contains(redacted, `"ref":"call `+Redacted+`"`) {
+		t.Errorf("expected dates to be kept and numbers to be redacted: %s", redacted)
+	}
+}
+
+func TestTransporterLogsRedactedBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"jobId":"J1","recipientIds":["+4917600000000"]}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	ts := createTransporter()
+	ts.Logger = slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: LevelBody}))
+
+	body := []byte(`{"messages":[{"text":"secret text","recipients":[{"dst":"+4917600000000"}]}]}`)
+	res := ts.DoDatacenterFetch([]string{server.URL}, "user", "password", body, "/jobs", http.MethodPost)
+	decoded, _ := io.ReadAll(res[0].Body)
+
+	logged := out.String()
+	for _, leak := range []string{"secret text", "+4917600000000", base64.StdEncoding.EncodeToString([]byte("user:password"))} {
+		if strings.Contains(logged, leak) {
+			t.Errorf("log contains %q: %s", leak, logged)
+		}
+	}
+	if !strings.Contains(logged, "status=200") || !strings.Contains(logged, "J1") {
+		t.Errorf("log is missing the request summary: %s", logged)
+	}
+	if !strings.Contains(string(decoded), "J1") {
+		t.Errorf("response body should still be readable after logging, got %q", decoded)
+	}
+}
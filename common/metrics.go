@@ -0,0 +1,105 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrorClass is a coarse classification of the outcome of a request, used as a metric label.
+type ErrorClass string
+
+const (
+	ErrorClassNone        ErrorClass = "none"
+	ErrorClassNotFound    ErrorClass = "not_found"
+	ErrorClassAuth        ErrorClass = "auth"
+	ErrorClassClient      ErrorClass = "client_error"
+	ErrorClassServer      ErrorClass = "server_error"
+	ErrorClassTimeout     ErrorClass = "timeout"
+	ErrorClassCanceled    ErrorClass = "canceled"
+	ErrorClassNetwork     ErrorClass = "network"
+	ErrorClassCircuitOpen ErrorClass = "circuit_open"
+)
+
+// ClassifyError returns the ErrorClass of a request which ended with the given response status
+// code or error.
+func ClassifyError(statusCode int, err error) ErrorClass {
+	if err != nil {
+		var netErr net.Error
+		switch {
+		case errors.Is(err, ErrCircuitOpen):
+			return ErrorClassCircuitOpen
+		case errors.Is(err, context.Canceled):
+			return ErrorClassCanceled
+		case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrorClassNotFound
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrorClassAuth
+	case statusCode >= http.StatusInternalServerError:
+		return ErrorClassServer
+	case statusCode >= http.StatusBadRequest:
+		return ErrorClassClient
+	}
+	return ErrorClassNone
+}
+
+// RequestObservation describes a single finished HTTP request to Retarus.
+type RequestObservation struct {
+	Service    string
+	Operation  string
+	Datacenter string
+	StatusCode int
+	Duration   time.Duration
+	ErrorClass ErrorClass
+}
+
+// Metrics receives the measurements of the clients, e.g. to export them to Prometheus.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveRequest records a finished request to a single datacenter or the high availability address.
+	ObserveRequest(o RequestObservation)
+	// AddSMSParts records the number of SMS parts of a successfully submitted job.
+	AddSMSParts(parts int)
+	// AddFaxPages records the number of fax pages of a successfully submitted job, counted over all recipients.
+	// Jobs with documents whose pages can't be counted locally, e.g. references and Office
+	// documents, aren't recorded, see fax.Estimate.
+	AddFaxPages(pages int)
+	// ObservePollingLag records how long after its completion a finished report was fetched.
+	ObservePollingLag(service string, lag time.Duration)
+}
+
+// observeRequest passes a finished request to the configured Metrics, if any.
+func (t *Transporter) observeRequest(call Call, datacenter string, started time.Time, res *http.Response, err error) {
+	if t.Metrics == nil {
+		return
+	}
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	t.Metrics.ObserveRequest(RequestObservation{
+		Service:    call.Service,
+		Operation:  call.Operation,
+		Datacenter: datacenter,
+		StatusCode: status,
+		Duration:   time.Since(started),
+		ErrorClass: ClassifyError(status, err),
+	})
+}
+
+// ObservePollingLag passes the lag between the completion of a report and the time it was
+// fetched to the configured Metrics, if any.
+func (t *Transporter) ObservePollingLag(service string, finished time.Time) {
+	if t.Metrics == nil || finished.IsZero() {
+		return
+	}
+	t.Metrics.ObservePollingLag(service, time.Since(finished))
+}
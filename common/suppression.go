@@ -0,0 +1,207 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSuppressed is returned by the clients if a job has suppressed recipients and the send
+	// options ask to reject it instead of removing them.
+	ErrSuppressed = errors.New("job has suppressed recipients")
+	// ErrAllSuppressed is returned by the clients if every recipient of a job is suppressed, the
+	// job isn't sent.
+	ErrAllSuppressed = errors.New("all recipients of the job are suppressed")
+)
+
+// SuppressionReason tells why a number is suppressed.
+type SuppressionReason string
+
+const (
+	// OptOut is a recipient who asked not to be contacted, e.g. with a STOP reply to an SMS.
+	OptOut SuppressionReason = "OPT_OUT"
+	// DoNotSend is a fax recipient who asked not to receive faxes.
+	DoNotSend SuppressionReason = "DO_NOT_SEND"
+	// HardBounce is a number which can't be delivered to, e.g. a disconnected line.
+	HardBounce SuppressionReason = "HARD_BOUNCE"
+)
+
+// SuppressionEntry is a number which must not be sent to.
+type SuppressionEntry struct {
+	Number string            `json:"number"`
+	Reason SuppressionReason `json:"reason"`
+	Since  time.Time         `json:"since"`
+	// Note (optional) is free text, e.g. where the opt-out was received.
+	Note string `json:"note,omitempty"`
+}
+
+// Suppression is a list of numbers which must not be sent to. The sms and fax clients look up
+// every recipient before a job is sent. Implementations must be safe for concurrent use, they
+// can be backed by a database shared by several processes.
+type Suppression interface {
+	// Lookup returns the entry of number, ok is false if the number isn't suppressed.
+	Lookup(ctx context.Context, number string) (entry SuppressionEntry, ok bool, err error)
+}
+
+// SuppressionKey returns the form numbers are compared in by MemorySuppression and
+// FileSuppression: without spaces, dashes, dots, slashes and parentheses, and with + instead of
+// the international prefix 00. National numbers aren't expanded, store numbers in the same
+// format the jobs use.
+func SuppressionKey(number string) string {
+	n := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -./()\u00a0", r) {
+			return -1
+		}
+		return r
+	}, number)
+	if strings.HasPrefix(n, "00") {
+		n = "+" + n[2:]
+	}
+	return n
+}
+
+// MemorySuppression is a Suppression held in memory.
+// Note: To create a new instance of MemorySuppression, use the NewMemorySuppression function.
+type MemorySuppression struct {
+	mu      sync.RWMutex
+	entries map[string]SuppressionEntry
+}
+
+// NewMemorySuppression creates a MemorySuppression with the given entries.
+func NewMemorySuppression(entries ...SuppressionEntry) *MemorySuppression {
+	s := &MemorySuppression{entries: map[string]SuppressionEntry{}}
+	for _, e := range entries {
+		s.Add(e)
+	}
+	return s
+}
+
+// Lookup returns the entry of number.
+func (s *MemorySuppression) Lookup(ctx context.Context, number string) (SuppressionEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[SuppressionKey(number)]
+	return e, ok, nil
+}
+
+// Add suppresses the number of the entry, or replaces its entry. A zero Since is set to now.
+func (s *MemorySuppression) Add(entry SuppressionEntry) {
+	if entry.Since.IsZero() {
+		entry.Since = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[SuppressionKey(entry.Number)] = entry
+}
+
+// Remove lifts the suppression of number, e.g. after the recipient opted in again.
+func (s *MemorySuppression) Remove(number string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, SuppressionKey(number))
+}
+
+// Entries returns all entries, sorted by number.
+func (s *MemorySuppression) Entries() []SuppressionEntry {
+	s.mu.RLock()
+	entries := make([]SuppressionEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return SuppressionKey(entries[i].Number) < SuppressionKey(entries[j].Number) })
+	return entries
+}
+
+// FileSuppression is a Suppression persisted in a file. Every change is appended as a JSON line,
+// the file is read completely when it is opened.
+// Note: To create a new instance of FileSuppression, use the OpenFileSuppression function.
+type FileSuppression struct {
+	*MemorySuppression
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// suppressionRecord is a line of the file of a FileSuppression.
+type suppressionRecord struct {
+	SuppressionEntry
+	Removed bool `json:"removed,omitempty"`
+}
+
+// OpenFileSuppression opens or creates the file of a FileSuppression and loads its entries.
+func OpenFileSuppression(path string) (*FileSuppression, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileSuppression{MemorySuppression: NewMemorySuppression(), file: file}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r suppressionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if r.Removed {
+			s.MemorySuppression.Remove(r.Number)
+		} else {
+			s.MemorySuppression.Add(r.SuppressionEntry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add suppresses the number of the entry and appends it to the file. A zero Since is set to now.
+func (s *FileSuppression) Add(entry SuppressionEntry) error {
+	if entry.Since.IsZero() {
+		entry.Since = time.Now()
+	}
+	if err := s.append(suppressionRecord{SuppressionEntry: entry}); err != nil {
+		return err
+	}
+	s.MemorySuppression.Add(entry)
+	return nil
+}
+
+// Remove lifts the suppression of number and appends the removal to the file.
+func (s *FileSuppression) Remove(number string) error {
+	if err := s.append(suppressionRecord{SuppressionEntry: SuppressionEntry{Number: number, Since: time.Now()}, Removed: true}); err != nil {
+		return err
+	}
+	s.MemorySuppression.Remove(number)
+	return nil
+}
+
+func (s *FileSuppression) append(r suppressionRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSuppression) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSuppressionKey(t *testing.T) {
+	for _, number := range []string{"+49 176 123-456", "0049 (176) 123.456", "+49176123456"} {
+		if got := SuppressionKey(number); got != "+49176123456" {
+			t.Errorf("SuppressionKey(%q) = %q", number, got)
+		}
+	}
+}
+
+func TestMemorySuppression(t *testing.T) {
+	ctx := context.Background()
+	since := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemorySuppression(SuppressionEntry{Number: "+49 176 123456", Reason: OptOut, Since: since})
+
+	entry, ok, err := s.Lookup(ctx, "0049176123456")
+	if err != nil || !ok {
+		t.Fatalf("expected the number to be suppressed, got %v, %v", ok, err)
+	}
+	if entry.Reason != OptOut || !entry.Since.Equal(since) {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if _, ok, _ := s.Lookup(ctx, "+49176654321"); ok {
+		t.Error("expected an unknown number not to be suppressed")
+	}
+
+	s.Add(SuppressionEntry{Number: "+4930123456", Reason: DoNotSend})
+	if entries := s.Entries(); len(entries) != 2 || entries[1].Number != "+4930123456" || entries[1].Since.IsZero() {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+	s.Remove("+49176123456")
+	if _, ok, _ := s.Lookup(ctx, "+49 176 123456"); ok {
+		t.Error("expected the removed number not to be suppressed")
+	}
+}
+
+func TestFileSuppression(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "suppression.jsonl")
+	s, err := OpenFileSuppression(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []SuppressionEntry{
+		{Number: "+49176123456", Reason: OptOut, Note: "STOP"},
+		{Number: "+4930123456", Reason: HardBounce},
+		{Number: "+4989123456", Reason: DoNotSend},
+	} {
+		if err := s.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Remove("+4930123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = OpenFileSuppression(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	entries := s.Entries()
+	if len(entries) != 2 || entries[0].Number != "+49176123456" || entries[0].Note != "STOP" || entries[1].Reason != DoNotSend {
+		t.Fatalf("unexpected entries after reopening %+v", entries)
+	}
+	if _, ok, _ := s.Lookup(ctx, "+4930123456"); ok {
+		t.Error("expected the removal to be persisted")
+	}
+}
+
+func TestFileSuppressionInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppression.jsonl")
+	if err := os.WriteFile(path, []byte("{\"number\":\"+49176123456\",\"reason\":\"OPT_OUT\"}\nnot json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileSuppression(path); err == nil {
+		t.Fatal("expected an error for the invalid line")
+	}
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ISO8601Layout is the layout timestamps are sent to the Retarus webservices with, e.g.
+// "2018-11-03T20:14:37.098+02:00", or "2018-11-03T18:14:37.098Z" in UTC.
+const ISO8601Layout = "2006-01-02T15:04:05.000Z07:00"
+
+// iso8601Layouts are the layouts a timestamp is parsed with, in this order. Go accepts fractional
+// seconds after the seconds of a layout without them.
+var iso8601Layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+	"2006-01-02",
+}
+
+// ISO8601Time is a timestamp of the Retarus webservices. It is formatted with ISO8601Layout in
+// the location of the time and parsed with or without a colon in the offset. The zero value is
+// encoded as null, use a *ISO8601Time field with omitempty for optional timestamps.
+type ISO8601Time struct {
+	time.Time
+}
+
+// NewISO8601Time returns a pointer to t, for optional timestamp fields.
+func NewISO8601Time(t time.Time) *ISO8601Time {
+	return &ISO8601Time{Time: t}
+}
+
+// ParseISO8601Time parses an ISO 8601 timestamp.
+func ParseISO8601Time(s string) (ISO8601Time, error) {
+	for _, layout := range iso8601Layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return ISO8601Time{Time: t}, nil
+		}
+	}
+	return ISO8601Time{}, fmt.Errorf("invalid ISO 8601 timestamp %q", s)
+}
+
+func (t ISO8601Time) String() string {
+	return t.Format(ISO8601Layout)
+}
+
+func (t ISO8601Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+func (t *ISO8601Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*t = ISO8601Time{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*t = ISO8601Time{}
+		return nil
+	}
+	parsed, err := ParseISO8601Time(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// FormatISO8601Duration formats d as an ISO 8601 duration like "PT1H20M", the largest unit is
+// hours.
+func FormatISO8601Duration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+		d -= m * time.Minute
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
+
+// ParseISO8601Duration parses an ISO 8601 duration of days, hours, minutes and seconds like
+// "P1DT12H" or "PT80M". Years, months and weeks aren't supported, they have no fixed length.
+func ParseISO8601Duration(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid ISO 8601 duration %q", s)
+	rest, sign := s, time.Duration(1)
+	if strings.HasPrefix(rest, "-") {
+		rest, sign = rest[1:], -1
+	}
+	rest, ok := strings.CutPrefix(rest, "P")
+	if !ok || rest == "" || strings.HasSuffix(rest, "T") {
+		return 0, invalid
+	}
+	var d time.Duration
+	inTime := false
+	units := "D"
+	for rest != "" {
+		if rest[0] == 'T' && !inTime {
+			rest, inTime, units = rest[1:], true, "HMS"
+			continue
+		}
+		i := strings.IndexAny(rest, "DHMS")
+		if i <= 0 || !strings.Contains(units, rest[i:i+1]) {
+			return 0, invalid
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil || n < 0 {
+			return 0, invalid
+		}
+		unit := map[byte]time.Duration{'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}[rest[i]]
+		d += time.Duration(n * float64(unit))
+		// every unit is allowed once and in order
+		units = units[strings.IndexByte(units, rest[i])+1:]
+		rest = rest[i+1:]
+	}
+	return sign * d, nil
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestISO8601TimeRoundTrip(t *testing.T) {
+	cest := time.FixedZone("CEST", 2*60*60)
+	stamp := time.Date(2018, 11, 3, 20, 14, 37, 98e6, cest)
+
+	data, err := json.Marshal(ISO8601Time{Time: stamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"2018-11-03T20:14:37.098+02:00"` {
+		t.Errorf("unexpected encoding %s", data)
+	}
+	var decoded ISO8601Time
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(stamp) {
+		t.Errorf("expected %v, got %v", stamp, decoded)
+	}
+	if _, offset := decoded.Zone(); offset != 2*60*60 {
+		t.Errorf("the offset got lost: %v", decoded)
+	}
+
+	utc, _ := json.Marshal(ISO8601Time{Time: stamp.UTC()})
+	if string(utc) != `"2018-11-03T18:14:37.098Z"` {
+		t.Errorf("unexpected UTC encoding %s", utc)
+	}
+}
+
+func TestISO8601TimeParsesAPIFormats(t *testing.T) {
+	want := time.Date(2018, 11, 3, 18, 14, 0, 0, time.UTC)
+	for _, s := range []string{
+		`"2018-11-03T20:14:00.000+02:00"`,
+		`"2018-11-03T20:14:00+0200"`,
+		`"2018-11-03T20:14+02:00"`,
+		`"2018-11-03T18:14Z"`,
+		`"2018-11-03T18:14:00.000000Z"`,
+	} {
+		var parsed ISO8601Time
+		if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+			t.Errorf("%s: %v", s, err)
+		} else if !parsed.Equal(want) {
+			t.Errorf("%s: expected %v, got %v", s, want, parsed)
+		}
+	}
+	var invalid ISO8601Time
+	if err := json.Unmarshal([]byte(`"yesterday"`), &invalid); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+}
+
+func TestISO8601TimeZeroValue(t *testing.T) {
+	var optional struct {
+		Required ISO8601Time  `json:"required"`
+		Optional *ISO8601Time `json:"optional,omitempty"`
+	}
+	data, _ := json.Marshal(optional)
+	if string(data) != `{"required":null}` {
+		t.Errorf("unexpected encoding of zero values %s", data)
+	}
+	if err := json.Unmarshal([]byte(`{"required":null,"optional":null}`), &optional); err != nil {
+		t.Fatal(err)
+	}
+	if !optional.Required.IsZero() || optional.Optional != nil {
+		t.Errorf("null should decode to the zero value, got %+v", optional)
+	}
+}
+
+func TestISO8601Duration(t *testing.T) {
+	for s, d := range map[string]time.Duration{
+		"PT80M":    80 * time.Minute,
+		"P1DT12H":  36 * time.Hour,
+		"PT1.5S":   1500 * time.Millisecond,
+		"P2D":      48 * time.Hour,
+		"-PT30M":   -30 * time.Minute,
+		"PT1H1M1S": time.Hour + time.Minute + time.Second,
+	} {
+		parsed, err := ParseISO8601Duration(s)
+		if err != nil || parsed != d {
+			t.Errorf("%s: expected %v, got %v %v", s, d, parsed, err)
+		}
+	}
+	for _, s := range []string{"", "P", "PT", "P1H", "PT1D", "PT1M1H", "P1Y", "PTxM"} {
+		if _, err := ParseISO8601Duration(s); err == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+	for d, s := range map[time.Duration]string{
+		80 * time.Minute:              "PT1H20M",
+		36 * time.Hour:                "PT36H",
+		1500 * time.Millisecond:       "PT1.5S",
+		0:                             "PT0S",
+		-(time.Hour + 30*time.Second): "-PT1H30S",
+	} {
+		if got := FormatISO8601Duration(d); got != s {
+			t.Errorf("%v: expected %s, got %s", d, s, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/retarus/retarus-go"
+
+// Span attribute keys set by the clients and the Transporter.
+const (
+	ServiceKey         = attribute.Key("retarus.service")
+	OperationKey       = attribute.Key("retarus.operation")
+	RegionKey          = attribute.Key("retarus.region")
+	DatacenterKey      = attribute.Key("retarus.datacenter")
+	JobIDKey           = attribute.Key("retarus.job_id")
+	JobCountKey        = attribute.Key("retarus.job_count")
+	RecipientCountKey  = attribute.Key("retarus.recipient_count")
+	SuppressedCountKey = attribute.Key("retarus.suppressed_count")
+	ReplayedKey        = attribute.Key("retarus.idempotent_replay")
+	HTTPStatusKey      = attribute.Key("http.response.status_code")
+	HTTPMethodKey      = attribute.Key("http.request.method")
+)
+
+// traceContext propagates the span of a call to Retarus in W3C trace context headers.
+var traceContext = propagation.TraceContext{}
+
+// tracer returns the tracer of the configured TracerProvider, or a no-op tracer if tracing is not enabled.
+func (t *Transporter) tracer() trace.Tracer {
+	if t.TracerProvider == nil {
+		return noop.NewTracerProvider().Tracer(instrumentationName)
+	}
+	return t.TracerProvider.Tracer(instrumentationName)
+}
+
+// StartSpan starts the span of a client call, e.g. "sms.Send". Without a TracerProvider the
+// returned span is a no-op.
+func (t *Transporter) StartSpan(ctx context.Context, service string, operation string, region Region, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		ServiceKey.String(service),
+		OperationKey.String(operation),
+		RegionKey.String(string(region)),
+	}, attrs...)
+	return t.tracer().Start(ctx, service+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// EndSpan records err on the span, if any, and ends it.
+func EndSpan(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startDatacenterSpan starts the span of a single datacenter request and injects its trace context into req.
+func (t *Transporter) startDatacenterSpan(req *http.Request, server string) trace.Span {
+	ctx, span := t.tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			DatacenterKey.String(server),
+			HTTPMethodKey.String(req.Method),
+		),
+	)
+	traceContext.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return span
+}
+
+// endDatacenterSpan records the outcome of a datacenter request on its span.
+func endDatacenterSpan(span trace.Span, res *http.Response, err error) {
+	if res != nil {
+		span.SetAttributes(HTTPStatusKey.Int(res.StatusCode))
+		if res.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+		}
+	}
+	EndSpan(span, err)
+}
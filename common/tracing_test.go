@@ -0,0 +1,112 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingProvider is a minimal TracerProvider which keeps every started span.
+type recordingProvider struct {
+	noop.TracerProvider
+	mu    *sync.Mutex
+	spans *[]*recordingSpan
+}
+
+func newRecordingProvider() recordingProvider {
+	return recordingProvider{mu: &sync.Mutex{}, spans: &[]*recordingSpan{}}
+}
+
+func (p recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{provider: p}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	provider recordingProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	t.provider.mu.Lock()
+	defer t.provider.mu.Unlock()
+	span := &recordingSpan{
+		name:  name,
+		attrs: map[attribute.Key]attribute.Value{},
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{byte(len(*t.provider.spans) + 1)},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	span.SetAttributes(cfg.Attributes()...)
+	*t.provider.spans = append(*t.provider.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	noop.Span
+	mu    sync.Mutex
+	name  string
+	attrs map[attribute.Key]attribute.Value
+	sc    trace.SpanContext
+	ended bool
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext { return s.sc }
+func (s *recordingSpan) IsRecording() bool              { return true }
+func (s *recordingSpan) End(...trace.SpanEndOption)     { s.ended = true }
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func TestDatacenterFetchTracing(t *testing.T) {
+	var mu sync.Mutex
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider := newRecordingProvider()
+	ts := createTransporter()
+	ts.TracerProvider = provider
+
+	ctx, span := ts.StartSpan(context.Background(), "fax", "GetReport", Europe, JobIDKey.String("FJ1"))
+	ts.DoDatacenterFetchContext(ctx, DatacenterRequest{Servers: []string{server.URL + "/dc1", server.URL + "/dc2"}, Method: http.MethodGet})
+	EndSpan(span, nil)
+
+	spans := *provider.spans
+	if len(spans) != 3 {
+		t.Fatalf("expected one client and two datacenter spans, got %d", len(spans))
+	}
+	if spans[0].name != "fax.GetReport" || spans[0].attrs[JobIDKey].AsString() != "FJ1" || spans[0].attrs[RegionKey].AsString() != "Europe" {
+		t.Errorf("client span is missing attributes: %s %v", spans[0].name, spans[0].attrs)
+	}
+	for _, s := range spans[1:] {
+		if s.attrs[HTTPStatusKey].AsInt64() != http.StatusNotFound || s.attrs[DatacenterKey].AsString() == "" {
+			t.Errorf("datacenter span is missing attributes: %v", s.attrs)
+		}
+		if !s.ended {
+			t.Errorf("datacenter span wasn't ended")
+		}
+	}
+	for _, tp := range traceparents {
+		if tp == "" || tp[3:35] != (trace.TraceID{1}).String() {
+			t.Errorf("trace context wasn't propagated, got traceparent %q", tp)
+		}
+	}
+}
@@ -2,64 +2,230 @@ package common
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Transporter struct {
 	HTTPClient http.Client
+
+	// Breakers holds a circuit breaker per datacenter, servers with an open breaker are skipped by
+	// DoDatacenterFetch. A nil value disables circuit breaking.
+	Breakers *BreakerGroup
+
+	// Limiters (optional) throttle the requests per operation class on the client side, calls
+	// block until their limiter lets them pass or their context is done.
+	Limiters map[OperationClass]*Limiter
+
+	// TracerProvider (optional) enables OpenTelemetry spans for every client call and datacenter
+	// request. The trace context is propagated to Retarus in W3C trace context headers.
+	TracerProvider trace.TracerProvider
+
+	// Metrics (optional) receives the measurements of every request.
+	Metrics Metrics
+
+	// Logger (optional) logs every request, see LevelSummary, LevelBody and LevelTransportError.
+	// Phone numbers, message texts, document data and credentials are redacted.
+	Logger *slog.Logger
+
+	// LogUnredacted disables the redaction of logged bodies and logs the Authorization header.
+	// Never enable it in production.
+	LogUnredacted bool
 }
 
 func NewTransporter(timeout int) Transporter {
 	return Transporter{
-		http.Client{Timeout: 5 * time.Second},
+		HTTPClient: http.Client{Timeout: time.Duration(timeout) * time.Second},
+		Breakers:   NewBreakerGroup(DefaultBreakerConfig()),
+	}
+}
+
+// SetLimiter sets the Limiter used for all calls of the given operation class.
+func (t *Transporter) SetLimiter(class OperationClass, limiter *Limiter) {
+	if t.Limiters == nil {
+		t.Limiters = map[OperationClass]*Limiter{}
+	}
+	t.Limiters[class] = limiter
+}
+
+// acquire waits for the limiter of the given class, if there is one.
+func (t *Transporter) acquire(ctx context.Context, class OperationClass) (release func(), err error) {
+	limiter := t.Limiters[class]
+	if limiter == nil {
+		return func() {}, ctx.Err()
 	}
+	return limiter.Acquire(ctx)
+}
+
+// Call identifies the client call a request belongs to. It selects the limiter of the request and
+// labels it in traces and metrics.
+type Call struct {
+	// Service is the Retarus service, e.g. "sms" or "fax".
+	Service string
+	// Operation is the name of the client method, e.g. "GetReport".
+	Operation string
+	// Class selects the limiter, the zero value is treated as ReportOperation.
+	Class OperationClass
+}
+
+// Do sends a single request, e.g. to the high availability address of a region, after waiting
+// for the limiter of the call.
+func (t *Transporter) Do(ctx context.Context, call Call, req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	release, err := t.acquire(ctx, call.class())
+	if err != nil {
+		t.observeRequest(call, req.URL.Host, started, nil, err)
+		return nil, err
+	}
+	defer release()
+	req = req.WithContext(ctx)
+	traceContext.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return t.roundTrip(call, req.URL.Host, req, started)
+}
+
+// roundTrip sends the request and passes the outcome to the configured metrics and logger.
+func (t *Transporter) roundTrip(call Call, datacenter string, req *http.Request, started time.Time) (*http.Response, error) {
+	res, err := t.HTTPClient.Do(req)
+	t.observeRequest(call, datacenter, started, res, err)
+	t.logRequest(req.Context(), call, datacenter, req, started, res, err)
+	return res, err
+}
+
+func (c Call) class() OperationClass {
+	if c.Class == "" {
+		return ReportOperation
+	}
+	return c.Class
 }
 
 type KvParams struct {
 	Key, Value string
 }
 
-func (t *Transporter) DoDatacenterFetch(servers []string, username string, password string, body []byte, resource string, method string, params ...KvParams) []*http.Response {
-	ch := make(chan *http.Response)
-	// Start two goroutines to fetch the URLs
-	for _, baseUrl := range servers {
-		go fetch(baseUrl+resource, body, method, username, password, ch)
+// DatacenterRequest describes a request which is sent to every datacenter of a region.
+type DatacenterRequest struct {
+	Call     Call
+	Servers  []string
+	Username string
+	Password string
+	Body     []byte
+	Resource string
+	Method   string
+	Params   []KvParams
+}
+
+// DatacenterResult is the outcome of a DatacenterRequest for a single server. Either Response
+// or Err is set, Err is ErrCircuitOpen if the server was skipped.
+type DatacenterResult struct {
+	Server   string
+	Response *http.Response
+	Err      error
+}
+
+// FetchDatacenters sends the request to all servers in parallel and returns one result per
+// server, in the order of req.Servers. The whole fan-out counts as one request for the limiter.
+func (t *Transporter) FetchDatacenters(ctx context.Context, req DatacenterRequest) []DatacenterResult {
+	results := make([]DatacenterResult, len(req.Servers))
+	started := time.Now()
+	release, err := t.acquire(ctx, req.Call.class())
+	if err != nil {
+		for i, baseUrl := range req.Servers {
+			results[i] = DatacenterResult{Server: baseUrl, Err: err}
+			t.observeRequest(req.Call, baseUrl, started, nil, err)
+		}
+		return results
 	}
+	defer release()
 
-	responses := []*http.Response{}
-	// Wait for results from both goroutines
-	for i := 0; i < len(servers); i++ {
-		x := <-ch
-		responses = append(responses, x)
+	done := make(chan struct{})
+	for i, baseUrl := range req.Servers {
+		results[i].Server = baseUrl
+		if t.Breakers != nil && !t.Breakers.Get(baseUrl).Allow() {
+			results[i].Err = ErrCircuitOpen
+			t.observeRequest(req.Call, baseUrl, started, nil, ErrCircuitOpen)
+			go func() { done <- struct{}{} }()
+			continue
+		}
+		go func(res *DatacenterResult) {
+			res.Response, res.Err = t.fetch(ctx, res.Server, req)
+			if t.Breakers != nil {
+				switch {
+				case errors.Is(res.Err, context.Canceled):
+					t.Breakers.Get(res.Server).Release()
+				case isDatacenterFailure(res.Response, res.Err):
+					t.Breakers.Get(res.Server).Failure()
+				default:
+					t.Breakers.Get(res.Server).Success()
+				}
+			}
+			done <- struct{}{}
+		}(&results[i])
 	}
+	for range req.Servers {
+		<-done
+	}
+	return results
+}
+
+// DoDatacenterFetch sends the request to all servers and returns the responses of every server
+// which could be reached. Servers with an open circuit breaker or a transport error are left out.
+func (t *Transporter) DoDatacenterFetch(servers []string, username string, password string, body []byte, resource string, method string, params ...KvParams) []*http.Response {
+	responses, _ := t.DoDatacenterFetchContext(context.Background(), DatacenterRequest{
+		Servers:  servers,
+		Username: username,
+		Password: password,
+		Body:     body,
+		Resource: resource,
+		Method:   method,
+		Params:   params,
+	})
 	return responses
 }
 
-func fetch(uri string, body []byte, method string, username string, password string, ch chan<- *http.Response, params ...KvParams) {
-	req, err := http.NewRequest(method, uri, bytes.NewReader(body))
-	if len(params) > 0 {
-		q := req.URL.Query()
-		for _, p := range params {
-			q.Add(p.Key, p.Value)
+// DoDatacenterFetchContext is like DoDatacenterFetch, but returns an error if ctx is done before
+// the responses were received.
+func (t *Transporter) DoDatacenterFetchContext(ctx context.Context, req DatacenterRequest) ([]*http.Response, error) {
+	results := t.FetchDatacenters(ctx, req)
+
+	responses := []*http.Response{}
+	for _, res := range results {
+		if res.Err == nil {
+			responses = append(responses, res.Response)
 		}
-		req.URL.RawQuery = q.Encode()
 	}
+	if err := ctx.Err(); err != nil {
+		for _, res := range responses {
+			res.Body.Close()
+		}
+		return nil, err
+	}
+	return responses, nil
+}
 
+func (t *Transporter) fetch(ctx context.Context, server string, r DatacenterRequest) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, r.Method, server+r.Resource, bytes.NewReader(r.Body))
 	if err != nil {
-		// bsider how you want to handle this error.
-		// For now, let's just return without sending anything to the channel.
-		return
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	if username != "" {
-		req.SetBasicAuth(username, password)
+	if len(r.Params) > 0 {
+		q := req.URL.Query()
+		for _, p := range r.Params {
+			q.Add(p.Key, p.Value)
+		}
+		req.URL.RawQuery = q.Encode()
 	}
-	client := &http.Client{Timeout: 5 * time.Second}
-	res, err := client.Do(req)
-	if err != nil {
-		// Handle this error as well. Perhaps you might want to send an error through the channel?
-		return
+	req.Header.Set("Content-Type", "application/json")
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
 	}
-	ch <- res // Send the response via the channel
+	span := t.startDatacenterSpan(req, server)
+	res, err := t.roundTrip(r.Call, server, req, time.Now())
+	endDatacenterSpan(span, res, err)
+	return res, err
 }
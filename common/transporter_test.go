@@ -44,17 +44,16 @@ func TestDatacenterFetch(t *testing.T) {
 }
 
 func TestDatacenterFetchWith404(t *testing.T) {
-	requestCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Got request ", r.URL.Path)
-		if requestCount == 1 {
+		// the datacenters are requested in parallel, so the response depends on the path and not
+		// on the order of the requests
+		if r.URL.Path == "/def" {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
 		// For example, respond with a 404 status
 		w.WriteHeader(http.StatusNotFound)
-		requestCount += 1
-		return
 	}))
 	defer server.Close()
 
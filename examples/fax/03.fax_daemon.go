@@ -9,32 +9,26 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/retarus/retarus-go/common"
 	"github.com/retarus/retarus-go/fax"
+	"github.com/retarus/retarus-go/outbox"
 	"github.com/urfave/cli/v2"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
-	"time"
 )
 
 var watcher *fsnotify.Watcher
 
-func prepareFax(filePath string) (string, error) {
-	// Read the entire file into a byte slice
-	data, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return "", err
-	}
-
-	// Encode the file content to base64
-	encoded := base64.StdEncoding.EncodeToString(data)
-	return encoded, nil
+func prepareFax(filePath string) (fax.Document, error) {
+	// Read the file, detect its type and encode it to base64
+	return fax.NewDocumentFromFile(filePath)
 }
 
 func writeJobReport(jobReport *fax.Report, outdir string) {
@@ -63,27 +57,17 @@ func writeJobReport(jobReport *fax.Report, outdir string) {
 	fmt.Println("JSON data written successfully!")
 }
 
-func pullFaxReportWorker(jobChannel chan string, faxClient fax.Client, outdir string) {
-	id, ok := <-jobChannel
-
-	time.Sleep(3 * time.Second)
-	if !ok {
-		log.Println("Channel closed, worker terminating")
+// writeFinishedReport stores the report of a fax the outbox has the final status of.
+func writeFinishedReport(entry outbox.Entry, faxClient *fax.Client, outdir string) {
+	if entry.State == outbox.Failed {
+		log.Printf("fax %s failed: %s", entry.ID, entry.Error)
 		return
 	}
-	isFinished := false
-	res, err := faxClient.GetReport(id)
-	// If job wasn't finished processing and got a 404, reschedule to fax means -> store it into channel
+	res, err := faxClient.GetReport(entry.JobID)
 	if err != nil {
-		isFinished = true
-		log.Fatalf("Could not find fax report for jobid: %c", id)
-	}
-
-	if !isFinished {
-		jobChannel <- id
+		log.Printf("Could not fetch fax report for jobid %s: %v", entry.JobID, err)
 		return
 	}
-
 	writeJobReport(res, outdir)
 }
 
@@ -99,9 +83,29 @@ func start(inDir string, outDir string) {
 	config := fax.NewConfigFromEnv(common.Europe)
 
 	faxClient := fax.NewClient(config)
-	jobChan := make(chan string)
-
-	go pullFaxReportWorker(jobChan, faxClient, outDir)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	// the idempotency keys survive a restart, so a fax whose send was interrupted isn't sent twice
+	idempotency, err := common.OpenFileIdempotencyStore(filepath.Join(outDir, "idempotency.jsonl"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer idempotency.Close()
+	faxClient.Idempotency = idempotency
+
+	// every fax is stored in the outbox before it is sent, faxes which weren't sent or confirmed
+	// when the daemon stopped are picked up again on the next start
+	box, err := outbox.Open(filepath.Join(outDir, "outbox.jsonl"), outbox.Options{
+		Fax:      &faxClient,
+		OnFinish: func(entry outbox.Entry) { writeFinishedReport(entry, &faxClient, outDir) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer box.Close()
+	log.Printf("%d faxes pending from the last run", box.Pending())
+	go box.Run(context.Background())
 
 	go func() {
 		for {
@@ -117,24 +121,26 @@ func start(inDir string, outDir string) {
 						filename := pathSplitted[len(pathSplitted)-1]
 						splitted := strings.Split(filename, ".")
 						if len(splitted) != 2 {
-							log.Fatal("Fax could not be send, naming schema was wrong.")
+							log.Println("skipping fax, naming schema was wrong:", filename)
+							continue
 						}
 						number := splitted[0]
-						fax_document_data, err := prepareFax(event.Name)
+						document, err := prepareFax(event.Name)
 						if err != nil {
-							panic("Could not read fax file from path")
+							log.Println("skipping file which can't be faxed:", err)
+							continue
 						}
 
 						job := fax.NewJob()
 						job.AddRecipient(fax.Recipient{Number: number})
-						job.AddDocument(fax.Document{Name: "test.pdf", Reference: "fax_daemon_job", Data: fax_document_data})
+						job.AddDocument(document)
 
-						res, err := faxClient.Send(job)
+						id, err := box.EnqueueFax("", job)
 						if err != nil {
-							panic("Could not send fax")
+							log.Println("Could not queue fax:", err)
+							continue
 						}
-						// send job id to fax report fetcher
-						jobChan <- res
+						log.Printf("queued %s as %s", filename, id)
 					}
 				}
 			case err, ok := <-watcher.Errors:
@@ -146,7 +152,7 @@ func start(inDir string, outDir string) {
 		}
 	}()
 
-	err := watcher.Add(inDir)
+	err = watcher.Add(inDir)
 	if err != nil {
 		log.Fatal(err)
 	}
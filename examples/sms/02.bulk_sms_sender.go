@@ -2,14 +2,13 @@
 package main
 
 import (
-	"encoding/csv"
 	"fmt"
-	"github.com/noirbizarre/gonja"
 	"github.com/retarus/retarus-go/common"
+	"github.com/retarus/retarus-go/importer"
 	"github.com/retarus/retarus-go/sms"
 	"log"
+	"log/slog"
 	"os"
-	"time"
 )
 
 func main() {
@@ -23,50 +22,37 @@ func main() {
 		Config:      config,
 		Transporter: common.NewTransporter(5),
 	}
+	// log a summary of every request, phone numbers and message texts are redacted
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: common.LevelSummary}))
+	client.Transporter.Logger = logger
 
-	// load csv file containing the campaign contact which will receive the sms
-	file, err := os.Open("assets/sms_data.csv")
+	// load campaign message
+	b, err := os.ReadFile("assets/advertisement.txt")
 	if err != nil {
-		fmt.Println("Could not find load sms csv list")
-		os.Exit(1)
+		log.Fatalf("failed to read the message template: %s", err)
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	tmpl, err := sms.NewTemplate(string(b))
 	if err != nil {
-		log.Fatalf("failed to read the CSV file: %s", err)
+		log.Fatalf("failed to parse the message template: %s", err)
 	}
-	messages := []sms.Message{}
-
-	// This script will only send one sms job which contains multiple messages (multiple sms) which each has different receivers.
-	for index, record := range records {
-		if index == 0 {
-			continue
-		}
-		// load campainge message
-		b, err := os.ReadFile("assets/advertisement.txt") // just pass the file name
-		if err != nil {
-			panic(err)
-		}
-		// render the template with the surname of the target.
-		tpl, err := gonja.FromString(string(b))
-		if err != nil {
-			panic(err)
-		}
-		out, err := tpl.Execute(gonja.Context{"firstname": "axel"})
-		if err != nil {
-			panic(err)
-		}
 
-		fmt.Println("index: ", index, "Record", record)
+	// load csv file containing the campaign contacts which will receive the sms, rows with
+	// invalid or duplicate numbers are reported and skipped.
+	list, err := importer.ReadFile("assets/sms_data.csv", importer.Options{Number: "number", CustomerRef: "id"})
+	if err != nil {
+		log.Fatalf("failed to read the contacts: %s", err)
+	}
+	for _, rejected := range list.Rejected {
+		logger.Warn("skipping contact", "line", rejected.Line, "reason", rejected.Reason)
+	}
 
-		// parse values into retarus data structure
-		recipient := []sms.Recipient{sms.NewRecipient(record[2], "example_02_go_sdk", []time.Time{})} // Assuming sms.Receipt exists
-		message := sms.NewMessage(out, recipient)
-		messages = append(messages, message) // Assign the result back to the messages slice.
+	// This script will only send one sms job, recipients with the same rendered text share a message.
+	// The template refers to the first_name column of the contacts.
+	messages, err := tmpl.Messages(list.TemplateRecipients(), &sms.Options{})
+	if err != nil {
+		log.Fatalf("failed to render the messages: %s", err)
 	}
-	fmt.Println("Messages: ", messages)
+	logger.Info("sending campaign", "messages", len(messages))
 	res, err := client.Send(sms.NewJob(messages, &sms.Options{}))
 
 	if err != nil {
@@ -0,0 +1,32 @@
+package fax
+
+import "context"
+
+// Sender is implemented by everything which can send fax jobs.
+type Sender interface {
+	Send(job Job) (jobID string, err error)
+	SendContext(ctx context.Context, job Job) (jobID string, err error)
+}
+
+// API holds the basic send and report methods of *Client. Code which depends on API instead of
+// *Client can be tested with a fake like faxtest.Recorder, and the client can be wrapped in
+// decorators, e.g. for auditing or caching, which implement API themselves. The helpers built on
+// top of these calls, like Broadcast, SendWithOptions, FindReports, Reports and EachReport, are
+// only available on *Client.
+type API interface {
+	Sender
+	GetReport(jobID string) (*Report, error)
+	GetReportContext(ctx context.Context, jobID string) (*Report, error)
+	GetReports() ([]Report, error)
+	GetReportsContext(ctx context.Context) ([]Report, error)
+	GetBulkReports(jobIDs []string) ([]Report, error)
+	GetBulkReportsContext(ctx context.Context, jobIDs []string) ([]Report, error)
+	DeleteReport(jobID string) (*DeleteReport, error)
+	DeleteReportContext(ctx context.Context, jobID string) (*DeleteReport, error)
+	DeleteReports() ([]DeleteReport, error)
+	DeleteReportsContext(ctx context.Context) ([]DeleteReport, error)
+	DeleteBulkReports(jobIDs []string) ([]DeleteReport, error)
+	DeleteBulkReportsContext(ctx context.Context, jobIDs []string) ([]DeleteReport, error)
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,230 @@
+package fax
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrStreamedBroadcast is returned by Broadcast if the template has streamed documents, which can
+// only be sent once. Use inline data or a DocumentCache instead.
+var ErrStreamedBroadcast = errors.New("streamed documents can't be broadcast")
+
+// Default options of Broadcast.
+const (
+	DefaultBroadcastBatchSize   = 100
+	DefaultBroadcastConcurrency = 4
+)
+
+// BroadcastOptions configures Broadcast, the zero value is usable.
+type BroadcastOptions struct {
+	// BatchSize is the maximum number of recipients per job, default DefaultBroadcastBatchSize.
+	BatchSize int
+	// Concurrency is the maximum number of jobs sent in parallel, default DefaultBroadcastConcurrency.
+	Concurrency int
+	// Manifest (optional) records the outcome per recipient. Recipients which already have a job
+	// ID in it are skipped, so a broadcast is resumed by passing the manifest of the previous run.
+	Manifest *Manifest
+}
+
+// ManifestEntry is the outcome of a broadcast for a single recipient.
+type ManifestEntry struct {
+	// Number is the fax number of the recipient.
+	Number string `json:"number"`
+	// JobID is the ID of the job the recipient was sent with, empty if sending failed.
+	JobID string `json:"jobId,omitempty"`
+	// Error is the error message of a failed job.
+	Error string `json:"error,omitempty"`
+}
+
+// Manifest maps the recipients of a broadcast to their job IDs and errors. A manifest opened with
+// OpenManifest appends every outcome to a file as soon as the job is sent, so a broadcast can be
+// resumed after a crash. A crash between sending a job and writing its outcome can still send
+// that batch twice.
+// Note: To create a new instance of Manifest, use the NewManifest or OpenManifest function.
+type Manifest struct {
+	mu      sync.Mutex
+	entries map[string]ManifestEntry
+	order   []string
+	file    *os.File
+}
+
+// NewManifest creates an empty in-memory Manifest.
+func NewManifest() *Manifest {
+	return &Manifest{entries: map[string]ManifestEntry{}}
+}
+
+// OpenManifest opens or creates a manifest file with one JSON entry per line and loads the
+// entries written by previous runs. The file must be closed with Close.
+func OpenManifest(path string) (*Manifest, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	m := NewManifest()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// the last line is incomplete if the process crashed while writing it
+			continue
+		}
+		m.set(e)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	m.file = file
+	return m, nil
+}
+
+// Entries returns the latest entry of every recipient, in the order the recipients were first recorded.
+func (m *Manifest) Entries() []ManifestEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]ManifestEntry, len(m.order))
+	for i, number := range m.order {
+		entries[i] = m.entries[number]
+	}
+	return entries
+}
+
+// JobID returns the job ID the recipient was sent with, or false if it wasn't sent successfully.
+func (m *Manifest) JobID(number string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entries[number]
+	return e.JobID, e.JobID != ""
+}
+
+// Close closes the manifest file, if any.
+func (m *Manifest) Close() error {
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
+}
+
+// record adds the outcome of a job and appends it to the manifest file.
+func (m *Manifest) record(entries []ManifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var lines []byte
+	for _, e := range entries {
+		m.set(e)
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if m.file == nil {
+		return nil
+	}
+	if _, err := m.file.Write(lines); err != nil {
+		return err
+	}
+	return m.file.Sync()
+}
+
+func (m *Manifest) set(e ManifestEntry) {
+	if _, ok := m.entries[e.Number]; !ok {
+		m.order = append(m.order, e.Number)
+	}
+	m.entries[e.Number] = e
+}
+
+// Broadcast sends the template job to many recipients, see the package function Broadcast.
+func (c *Client) Broadcast(ctx context.Context, template Job, recipients []Recipient, opts BroadcastOptions) (*Manifest, error) {
+	return Broadcast(ctx, c, template, recipients, opts)
+}
+
+// Broadcast sends the template job to the recipients, split into jobs of at most
+// opts.BatchSize recipients which are sent with at most opts.Concurrency requests in parallel.
+// The recipients of the template are replaced, the Properties of each recipient personalize its
+// cover page. The outcome of every recipient is recorded in the returned manifest, failed jobs
+// don't stop the broadcast. An error is only returned if the broadcast couldn't be completed,
+// e.g. because ctx was canceled or the manifest couldn't be written. After the first manifest
+// write error no further batches are sent, the jobs already in flight are completed.
+func Broadcast(ctx context.Context, sender Sender, template Job, recipients []Recipient, opts BroadcastOptions) (*Manifest, error) {
+	if streamed(template) {
+		return nil, ErrStreamedBroadcast
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBroadcastBatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultBroadcastConcurrency
+	}
+	manifest := opts.Manifest
+	if manifest == nil {
+		manifest = NewManifest()
+	}
+
+	pending := []Recipient{}
+	for _, r := range recipients {
+		if _, sent := manifest.JobID(r.Number); !sent {
+			pending = append(pending, r)
+		}
+	}
+
+	// stopped is closed on the first manifest write error, the batches which haven't been sent yet
+	// are left out, because their outcome couldn't be recorded for a resume either.
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var broadcastErr error
+	stopped := make(chan struct{})
+	sem := make(chan struct{}, opts.Concurrency)
+	for start := 0; start < len(pending); start += opts.BatchSize {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errOnce.Do(func() { broadcastErr = ctx.Err() })
+		case <-stopped:
+		}
+		if ctx.Err() != nil || isClosed(stopped) {
+			break
+		}
+		batch := pending[start:min(start+opts.BatchSize, len(pending))]
+		wg.Add(1)
+		go func(batch []Recipient) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			job := template
+			job.Recipients = batch
+			jobID, err := sender.SendContext(ctx, job)
+			entries := make([]ManifestEntry, len(batch))
+			for i, r := range batch {
+				entries[i] = ManifestEntry{Number: r.Number, JobID: jobID}
+				if err != nil {
+					entries[i] = ManifestEntry{Number: r.Number, Error: err.Error()}
+				}
+			}
+			if err := manifest.record(entries); err != nil {
+				errOnce.Do(func() {
+					broadcastErr = err
+					close(stopped)
+				})
+			}
+		}(batch)
+	}
+	wg.Wait()
+	if broadcastErr == nil {
+		broadcastErr = ctx.Err()
+	}
+	return manifest, broadcastErr
+}
+
+// isClosed reports whether ch is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
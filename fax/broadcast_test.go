@@ -0,0 +1,154 @@
+package fax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func broadcastRecipients(n int) []Recipient {
+	recipients := make([]Recipient, n)
+	for i := range recipients {
+		recipients[i] = Recipient{
+			Number:     fmt.Sprintf("+49890000%05d", i),
+			Properties: []RecipientProperty{{Key: "name", Value: fmt.Sprintf("Customer %d", i)}},
+		}
+	}
+	return recipients
+}
+
+func broadcastTemplate() Job {
+	return Job{
+		Documents:        []Document{{Name: "notice.txt", Data: "dGVzdGZheAo="}},
+		RenderingOptions: &RenderingOptions{PaperFormat: A4, CoverpageTemplate: "notice.ftl.html"},
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	recipients := broadcastRecipients(250)
+
+	manifest, err := client.Broadcast(context.Background(), broadcastTemplate(), recipients, BroadcastOptions{BatchSize: 100, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := manifest.Entries()
+	if len(entries) != 250 {
+		t.Fatalf("expected 250 manifest entries, got %d", len(entries))
+	}
+	jobs := map[string]bool{}
+	for _, e := range entries {
+		if e.JobID == "" || e.Error != "" {
+			t.Errorf("recipient %s wasn't sent: %s", e.Number, e.Error)
+		}
+		jobs[e.JobID] = true
+	}
+	if len(jobs) != 3 {
+		t.Errorf("expected 3 jobs, got %d", len(jobs))
+	}
+
+	jobID, _ := manifest.JobID(recipients[142].Number)
+	var sent Job
+	json.Unmarshal(srv.Job(jobID), &sent)
+	found := false
+	for _, r := range sent.Recipients {
+		if r.Number == recipients[142].Number {
+			found = len(r.Properties) == 1 && r.Properties[0].Value == "Customer 142"
+		}
+	}
+	if !found || sent.RenderingOptions.CoverpageTemplate != "notice.ftl.html" {
+		t.Errorf("job %s lost the personalization of the recipient: %+v", jobID, sent.Recipients)
+	}
+}
+
+// failingSender fails every job after the first ones.
+type failingSender struct {
+	Sender
+	succeed int32
+}
+
+func (s *failingSender) SendContext(ctx context.Context, job Job) (string, error) {
+	if atomic.AddInt32(&s.succeed, -1) < 0 {
+		return "", errors.New("connection reset")
+	}
+	return s.Sender.SendContext(ctx, job)
+}
+
+func TestBroadcastResume(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	recipients := broadcastRecipients(50)
+	path := filepath.Join(t.TempDir(), "manifest.jsonl")
+
+	manifest, err := OpenManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &failingSender{Sender: &client, succeed: 2}
+	opts := BroadcastOptions{BatchSize: 10, Concurrency: 1, Manifest: manifest}
+	if _, err := Broadcast(context.Background(), sender, broadcastTemplate(), recipients, opts); err != nil {
+		t.Fatal(err)
+	}
+	manifest.Close()
+
+	// a new process resumes the broadcast from the manifest file
+	manifest, err = OpenManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manifest.Close()
+	failed := 0
+	for _, e := range manifest.Entries() {
+		if e.Error != "" {
+			failed++
+		}
+	}
+	if failed != 30 {
+		t.Fatalf("expected 30 failed recipients in the manifest, got %d", failed)
+	}
+
+	sender = &failingSender{Sender: &client, succeed: 100}
+	opts.Manifest = manifest
+	if _, err := Broadcast(context.Background(), sender, broadcastTemplate(), recipients, opts); err != nil {
+		t.Fatal(err)
+	}
+	if sent := 100 - sender.succeed; sent != 3 {
+		t.Errorf("only the 3 failed batches should be resent, got %d jobs", sent)
+	}
+	for _, e := range manifest.Entries() {
+		if e.JobID == "" {
+			t.Errorf("recipient %s wasn't sent after resuming", e.Number)
+		}
+	}
+}
+
+func TestBroadcastStopsOnManifestError(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	manifest, err := OpenManifest(filepath.Join(t.TempDir(), "manifest.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the outcome of the first batch can't be written
+	manifest.file.Close()
+	sender := &failingSender{Sender: &client, succeed: 100}
+	opts := BroadcastOptions{BatchSize: 10, Concurrency: 1, Manifest: manifest}
+
+	if _, err := Broadcast(context.Background(), sender, broadcastTemplate(), broadcastRecipients(50), opts); err == nil {
+		t.Fatal("expected the manifest error")
+	}
+	if sent := 100 - sender.succeed; sent != 1 {
+		t.Errorf("expected no batch to be sent after the manifest error, got %d jobs", sent)
+	}
+}
+
+func TestBroadcastRejectsStreamedDocuments(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	template := Job{Documents: []Document{{Name: "a.txt", Content: strings.NewReader("once")}}}
+	if _, err := client.Broadcast(context.Background(), template, broadcastRecipients(2), BroadcastOptions{}); !errors.Is(err, ErrStreamedBroadcast) {
+		t.Errorf("expected ErrStreamedBroadcast, got %v", err)
+	}
+}
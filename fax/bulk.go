@@ -0,0 +1,162 @@
+package fax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+// MaxBulkJobIDs is the maximum number of job IDs the service accepts per bulk request. Bulk
+// operations with more job IDs are split into chunks of this size.
+const MaxBulkJobIDs = 1000
+
+// bulkConcurrency is the number of chunks which are requested in parallel, each of them from
+// all datacenters.
+const bulkConcurrency = 4
+
+// BulkReports is the result of FindReports.
+type BulkReports struct {
+	// Reports are the found reports, in the order of the requested job IDs.
+	Reports []Report
+	// NotFound are the requested job IDs which no datacenter has a report for.
+	NotFound []string
+}
+
+// FindReports gets the reports of any number of job IDs from all datacenters. The job IDs are
+// requested in chunks of MaxBulkJobIDs in parallel. If a datacenter fails, the reports found in
+// the others are returned together with the error, and job IDs which might be in the failed
+// datacenter aren't listed as NotFound.
+func (c *Client) FindReports(jobIDs []string) (BulkReports, error) {
+	return c.FindReportsContext(context.Background(), jobIDs)
+}
+
+// FindReportsContext is like FindReports, but waits for the client side report limiter under the control of ctx.
+func (c *Client) FindReportsContext(ctx context.Context, jobIDs []string) (result BulkReports, err error) {
+	ctx, span := c.startSpan(ctx, "GetBulkReports", common.JobCountKey.Int(len(jobIDs)))
+	defer func() { common.EndSpan(span, err) }()
+
+	jobIDs = uniqueIDs(jobIDs)
+	found := make([]*Report, len(jobIDs))
+	errs := make([]error, (len(jobIDs)+MaxBulkJobIDs-1)/MaxBulkJobIDs)
+	answered := make([]bool, len(errs))
+	forChunks(jobIDs, func(i int, chunk []string) {
+		reports, err := c.getBulkChunk(ctx, chunk)
+		errs[i], answered[i] = err, reports != nil
+		for j, id := range chunk {
+			if r, ok := reports[id]; ok {
+				found[i*MaxBulkJobIDs+j] = &r
+			}
+		}
+	})
+
+	var failed []error
+	anyAnswered := false
+	for i, id := range jobIDs {
+		if found[i] != nil {
+			result.Reports = append(result.Reports, *found[i])
+		} else if errs[i/MaxBulkJobIDs] == nil {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+		anyAnswered = anyAnswered || answered[i]
+	}
+	if !anyAnswered && len(failed) > 0 {
+		return BulkReports{}, errors.Join(failed...)
+	}
+	return result, errors.Join(failed...)
+}
+
+// getBulkChunk gets the reports of at most MaxBulkJobIDs job IDs from all datacenters. The error
+// is set if a datacenter failed, the reports are nil if no datacenter answered.
+func (c *Client) getBulkChunk(ctx context.Context, jobIDs []string) (map[string]Report, error) {
+	body, err := json.Marshal(bulkReportRequest{Action: "GET", JobIDs: jobIDs})
+	if err != nil {
+		return nil, err
+	}
+	results := c.Transporter.FetchDatacenters(ctx, common.DatacenterRequest{
+		Call:     common.Call{Service: "fax", Operation: "GetBulkReports", Class: common.ReportOperation},
+		Servers:  c.Config.Region.Servers,
+		Username: c.Config.User,
+		Password: c.Config.Password,
+		Body:     body,
+		Resource: c.Config.CustomerNumber + "/fax/reports",
+		Method:   http.MethodPost,
+	})
+	reports := map[string]Report{}
+	var errs []error
+	for _, res := range results {
+		if res.Err == nil {
+			res.Err = decodeBulkReports(res.Response, reports)
+		}
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("datacenter %s: %w", res.Server, res.Err))
+		}
+	}
+	if len(errs) == len(results) && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return reports, errors.Join(errs...)
+}
+
+func decodeBulkReports(res *http.Response, reports map[string]Report) error {
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		io.Copy(io.Discard, res.Body)
+		return nil
+	case http.StatusOK:
+	default:
+		return statusToError(res.StatusCode, res.Body)
+	}
+	var list struct {
+		Reports []Report `json:"reports"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return err
+	}
+	for _, r := range list.Reports {
+		reports[r.JobID] = r
+	}
+	return nil
+}
+
+// forChunks calls fn for every chunk of at most MaxBulkJobIDs job IDs, with at most
+// bulkConcurrency calls in parallel. i is the index of the chunk.
+func forChunks(jobIDs []string, fn func(i int, chunk []string)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, bulkConcurrency)
+	for i := 0; i*MaxBulkJobIDs < len(jobIDs); i++ {
+		chunk := jobIDs[i*MaxBulkJobIDs : min((i+1)*MaxBulkJobIDs, len(jobIDs))]
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, chunk []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i, chunk)
+		}(i, chunk)
+	}
+	wg.Wait()
+}
+
+// uniqueIDs removes duplicate job IDs and keeps the order of their first occurrence.
+func uniqueIDs(jobIDs []string) []string {
+	seen := make(map[string]bool, len(jobIDs))
+	unique := make([]string, 0, len(jobIDs))
+	for _, id := range jobIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
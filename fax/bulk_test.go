@@ -0,0 +1,133 @@
+package fax
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// bulkJobIDs returns n job IDs, with jobIDs spread over the chunks and unknown IDs in between.
+func bulkJobIDs(jobIDs []string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("FJ-UNKNOWN-%d", i)
+	}
+	for i, id := range jobIDs {
+		ids[(i+1)*n/(len(jobIDs)+1)] = id
+	}
+	return ids
+}
+
+func TestFindReportsChunks(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 3)
+	requested := bulkJobIDs(jobIDs, 2500)
+
+	res, err := client.FindReports(append(requested, jobIDs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Reports) != 3 || len(res.NotFound) != 2497 {
+		t.Fatalf("expected 3 reports and 2497 missing job ids, got %d and %d", len(res.Reports), len(res.NotFound))
+	}
+	for i, r := range res.Reports {
+		if r.JobID != jobIDs[i] {
+			t.Errorf("report %d is for %s instead of %s", i, r.JobID, jobIDs[i])
+		}
+	}
+	if res.NotFound[0] != "FJ-UNKNOWN-0" || res.NotFound[2496] != "FJ-UNKNOWN-2499" {
+		t.Errorf("missing job ids aren't in request order: %v ... %v", res.NotFound[0], res.NotFound[2496])
+	}
+
+	reports, err := client.GetBulkReports(requested)
+	if err != nil || len(reports) != 3 {
+		t.Errorf("expected the 3 reports, got %d %v", len(reports), err)
+	}
+}
+
+func TestFindReportsWithFailedDatacenter(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 2)
+	srv.JobDatacenter(jobIDs[1]).FailWith(http.StatusInternalServerError)
+
+	res, err := client.FindReports(append(jobIDs, "FJ-UNKNOWN"))
+	if err == nil {
+		t.Error("expected the error of the failed datacenter")
+	}
+	if len(res.Reports) != 1 || res.Reports[0].JobID != jobIDs[0] || len(res.NotFound) != 0 {
+		t.Errorf("expected the report of the healthy datacenter only, got %+v", res)
+	}
+}
+
+func TestDeleteBulkReportsChunks(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 3)
+	requested := bulkJobIDs(jobIDs, 2500)
+
+	res, err := client.DeleteBulkReports(requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(requested) {
+		t.Fatalf("expected one result per job id, got %d", len(res))
+	}
+	deleted := 0
+	for i, r := range res {
+		if r.JobID != requested[i] {
+			t.Fatalf("result %d is for %s instead of %s", i, r.JobID, requested[i])
+		}
+		if r.Deleted {
+			deleted++
+		} else if r.Reason != ReasonNotFound {
+			t.Errorf("job %s should be NOT_FOUND, got %+v", r.JobID, r)
+		}
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted reports, got %d", deleted)
+	}
+	for _, id := range jobIDs {
+		if srv.JobDatacenter(id) != nil {
+			t.Errorf("report of %s wasn't deleted", id)
+		}
+	}
+}
+
+// failingChunkTransport fails the requests whose body contains the marker.
+type failingChunkTransport struct {
+	marker []byte
+}
+
+func (t failingChunkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	if bytes.Contains(body, t.marker) {
+		return nil, errors.New("connection reset")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDeleteBulkReportsWithFailedChunk(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 3)
+	requested := bulkJobIDs(jobIDs, 2500)
+	client.Transporter.HTTPClient.Transport = failingChunkTransport{marker: []byte(`"FJ-UNKNOWN-2499"`)}
+
+	res, err := client.DeleteBulkReports(requested)
+	if err == nil {
+		t.Error("expected the error of the failed chunk")
+	}
+	if len(res) != len(requested) {
+		t.Fatalf("expected the results of the other chunks, got %d", len(res))
+	}
+	if res[0].Reason != ReasonNotFound || res[2499].Reason != ReasonInternalError || res[2499].Err == nil {
+		t.Errorf("unexpected results %+v and %+v", res[0], res[2499])
+	}
+	for _, id := range jobIDs {
+		if srv.JobDatacenter(id) != nil {
+			t.Errorf("report of %s in a healthy chunk wasn't deleted", id)
+		}
+	}
+}
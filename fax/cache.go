@@ -0,0 +1,156 @@
+package fax
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// DocumentStore makes documents available for download by the fax service.
+//
+// The fax webservice has no endpoints to upload, list or delete documents: a Document is either
+// sent inline or references a URL which Retarus downloads when the job is processed. Cover page
+// templates and overlays are installed by Retarus and referenced by name in RenderingOptions.
+// A DocumentStore is therefore backed by your own storage, e.g. a bucket with pre-signed URLs.
+type DocumentStore interface {
+	// Put stores the document content under the given key, if it isn't stored yet, and returns
+	// the URL under which the fax service can download it.
+	Put(ctx context.Context, key string, data []byte) (string, error)
+}
+
+// DocumentCache replaces the content of documents with references to a DocumentStore. The content
+// is addressed by its SHA-256 hash, so an attachment sent to thousands of recipients in separate
+// jobs is stored once and only referenced afterwards.
+// Note: To create a new instance of DocumentCache, use the NewDocumentCache function.
+type DocumentCache struct {
+	store DocumentStore
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	done chan struct{}
+	url  string
+	err  error
+}
+
+// NewDocumentCache creates a DocumentCache which stores documents in the given store.
+func NewDocumentCache(store DocumentStore) *DocumentCache {
+	return &DocumentCache{store: store, entries: map[string]*cacheEntry{}}
+}
+
+// Reference returns the document with its content replaced by a reference to the store. The
+// Content of streamed documents is read completely. Documents which already have a Reference are
+// returned as they are.
+func (c *DocumentCache) Reference(ctx context.Context, d Document) (Document, error) {
+	if d.Reference != "" {
+		return d, nil
+	}
+	var data []byte
+	var err error
+	if d.Content != nil {
+		data, err = io.ReadAll(d.Content)
+	} else {
+		data, err = base64.StdEncoding.DecodeString(d.Data)
+	}
+	if err != nil {
+		return Document{}, err
+	}
+	if len(data) == 0 {
+		return Document{}, ErrEmptyDocument
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:]) + path.Ext(d.Name)
+	u, err := c.put(ctx, key, data)
+	if err != nil {
+		return Document{}, err
+	}
+	return Document{Name: d.Name, Charset: d.Charset, Reference: u}, nil
+}
+
+// ReferenceJob returns a copy of the job whose documents are replaced by references, see Reference.
+func (c *DocumentCache) ReferenceJob(ctx context.Context, job Job) (Job, error) {
+	documents := make([]Document, len(job.Documents))
+	for i, d := range job.Documents {
+		ref, err := c.Reference(ctx, d)
+		if err != nil {
+			return Job{}, err
+		}
+		documents[i] = ref
+	}
+	job.Documents = documents
+	return job, nil
+}
+
+// put stores the content once per key, concurrent calls for the same key wait for the first one.
+// Failed stores are not cached.
+func (c *DocumentCache) put(ctx context.Context, key string, data []byte) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{done: make(chan struct{})}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.done:
+			return e.url, e.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	e.url, e.err = c.store.Put(ctx, key, data)
+	if e.err != nil {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.url, e.err
+}
+
+// DirStore is a DocumentStore which writes documents to a directory that is served by a web
+// server reachable by Retarus under BaseURL.
+type DirStore struct {
+	Dir     string
+	BaseURL string
+}
+
+// Put implements DocumentStore.
+func (s DirStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	file := filepath.Join(s.Dir, key)
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		// write to a temporary file first, so the web server never serves a partial document
+		tmp, err := os.CreateTemp(s.Dir, key+".*.tmp")
+		if err != nil {
+			return "", err
+		}
+		_, err = tmp.Write(data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), file)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+	return url.JoinPath(s.BaseURL, key)
+}
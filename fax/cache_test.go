@@ -0,0 +1,100 @@
+package fax
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type countingStore struct {
+	mu   sync.Mutex
+	puts map[string]int
+	err  error
+}
+
+func (s *countingStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	s.puts[key]++
+	return "https://files.example.com/" + key, nil
+}
+
+func TestDocumentCacheStoresOnce(t *testing.T) {
+	store := &countingStore{puts: map[string]int{}}
+	cache := NewDocumentCache(store)
+	document := Document{Name: "terms.txt", Charset: UTF_8, Data: "dGVzdGZheAo="}
+
+	var wg sync.WaitGroup
+	refs := make([]Document, 50)
+	for i := range refs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			job, err := cache.ReferenceJob(context.Background(), Job{Documents: []Document{document}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			refs[i] = job.Documents[0]
+		}(i)
+	}
+	wg.Wait()
+
+	if len(store.puts) != 1 {
+		t.Fatalf("expected one stored document, got %v", store.puts)
+	}
+	for key, n := range store.puts {
+		if n != 1 || !strings.HasSuffix(key, ".txt") {
+			t.Errorf("document %s stored %d times", key, n)
+		}
+	}
+	for _, ref := range refs {
+		if ref != refs[0] || ref.Data != "" || ref.Name != "terms.txt" || ref.Charset != UTF_8 {
+			t.Errorf("unexpected reference %+v", ref)
+		}
+	}
+
+	streamed := Document{Name: "terms.txt", Content: strings.NewReader("testfax\n")}
+	if ref, _ := cache.Reference(context.Background(), streamed); ref.Reference != refs[0].Reference {
+		t.Errorf("same content should have the same reference, got %s", ref.Reference)
+	}
+}
+
+func TestDocumentCacheRetriesFailedStores(t *testing.T) {
+	store := &countingStore{puts: map[string]int{}, err: errors.New("bucket unavailable")}
+	cache := NewDocumentCache(store)
+	document := Document{Name: "a.txt", Data: "dGVzdGZheAo="}
+
+	if _, err := cache.Reference(context.Background(), document); err == nil {
+		t.Fatal("expected the store error")
+	}
+	store.err = nil
+	if ref, err := cache.Reference(context.Background(), document); err != nil || ref.Reference == "" {
+		t.Errorf("failed stores shouldn't be cached, got %v", err)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	store := DirStore{Dir: dir, BaseURL: "https://files.example.com/fax"}
+	for i := 0; i < 2; i++ {
+		u, err := store.Put(context.Background(), "abc.pdf", []byte("%PDF-1.4"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != "https://files.example.com/fax/abc.pdf" {
+			t.Errorf("unexpected URL %s", u)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if data, _ := os.ReadFile(filepath.Join(dir, "abc.pdf")); len(entries) != 1 || string(data) != "%PDF-1.4" {
+		t.Errorf("unexpected directory content %v", entries)
+	}
+}
@@ -1,12 +1,17 @@
 package fax
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
 	"github.com/retarus/retarus-go/common"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client is responsible for sending fax requests and handling transportation for a fax service.
@@ -18,6 +23,13 @@ type Client struct {
 
 	// Transporter is responsible for the actual HTTP requests and responses.
 	Transporter common.Transporter
+
+	// Suppression (optional) is looked up for every recipient number before a job is sent,
+	// suppressed recipients and alternative numbers are removed from the job.
+	Suppression common.Suppression
+
+	// Idempotency (optional) stores the job IDs of sends with SendOptions.IdempotencyKey.
+	Idempotency common.IdempotencyStore
 }
 
 // NewClient creates and returns a new FaxClient instance.
@@ -25,6 +37,8 @@ type Client struct {
 // The Transporter is initialized with a default timeout of 5 seconds.
 //
 // This is the preferred way to create a new FaxClient instance.
+// The methods of Client have pointer receivers, so *Client implements the API and Sender
+// interfaces; depend on those interfaces to substitute the client in tests.
 //
 // Parameters:
 //   - config: A Config object containing settings like API keys, base URLs, etc.
@@ -40,29 +54,111 @@ func NewClient(config Config) Client {
 
 // Send sends a fax job to the specified numbers in the job.
 func (c *Client) Send(job Job) (jobID string, err error) {
-	jobBytes, err := json.Marshal(job)
+	return c.SendContext(context.Background(), job)
+}
+
+// SendContext is like Send, but waits for the client side send limiter under the control of ctx.
+func (c *Client) SendContext(ctx context.Context, job Job) (jobID string, err error) {
+	result, err := c.SendWithOptions(ctx, job, SendOptions{})
+	return result.JobID, err
+}
+
+// SendWithOptions is like SendContext, and returns the recipients and alternative numbers which
+// were removed from the job because they are suppressed. If every recipient is suppressed, the job
+// isn't sent and the error is common.ErrAllSuppressed.
+//
+// If opts has an IdempotencyKey which the Idempotency store of the client already has a job ID for,
+// the job isn't sent again and the result has this job ID with Replayed set. A concurrent send with
+// the same key fails with common.ErrIdempotencyKeyInUse.
+// With an IdempotencyKey, a job without Reference.CustomerDefinedID gets the key as ID, so its
+// report can be matched to the key even if the key couldn't be stored.
+func (c *Client) SendWithOptions(ctx context.Context, job Job, opts SendOptions) (result SendResult, err error) {
+	ctx, span := c.startSpan(ctx, "Send", common.RecipientCountKey.Int(len(job.Recipients)))
+	defer func() { common.EndSpan(span, err) }()
+
+	// rejected is cleared once the request is sent, unless the service rejects the job
+	rejected := true
+	if key := opts.IdempotencyKey; key != "" {
+		if c.Idempotency == nil {
+			return result, common.ErrNoIdempotencyStore
+		}
+		jobID, err := c.Idempotency.Claim(ctx, key)
+		if err != nil {
+			return result, err
+		}
+		if jobID != "" {
+			span.SetAttributes(common.ReplayedKey.Bool(true), common.JobIDKey.String(jobID))
+			return SendResult{JobID: jobID, Replayed: true}, nil
+		}
+		defer func() { err = common.SettleIdempotencyKey(ctx, c.Idempotency, key, result.JobID, err, rejected) }()
+		job = withCustomerDefinedID(job, opts.IdempotencyKey)
+	}
+
+	job, result.Suppressed, err = suppress(ctx, c.Suppression, job)
 	if err != nil {
-		return "", err
+		return result, err
+	}
+	if len(result.Suppressed) > 0 {
+		span.SetAttributes(common.SuppressedCountKey.Int(len(result.Suppressed)))
+		if opts.RejectSuppressed {
+			return result, fmt.Errorf("%w: %d numbers", common.ErrSuppressed, len(result.Suppressed))
+		}
+		if len(job.Recipients) == 0 {
+			return result, common.ErrAllSuppressed
+		}
+	}
+	result.JobID, rejected, err = c.send(ctx, span, job)
+	return result, err
+}
+
+// send posts the job, the span of the caller gets the HTTP status and the job ID. rejected is set
+// if the job certainly wasn't created.
+func (c *Client) send(ctx context.Context, span trace.Span, job Job) (jobID string, rejected bool, err error) {
+	maxDocument, maxJob := c.Config.sizeLimits()
+	if err := checkInlineSizes(job, maxDocument, maxJob); err != nil {
+		return "", true, err
 	}
 	u, err := url.JoinPath(string(c.Config.Region.HAAddr), "/", c.Config.CustomerNumber, "/fax")
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
-	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(jobBytes))
+
+	// the job is encoded while it is sent, so document data isn't copied into memory, the pages
+	// are counted on the way for the metrics
+	var pages *int
+	if c.Transporter.Metrics != nil {
+		pages = new(int)
+	}
+	body, pw := io.Pipe()
+	defer body.Close()
+	encoded := make(chan error, 1)
+	go func() {
+		err := encodeJob(pw, job, maxDocument, maxJob, pages)
+		pw.CloseWithError(err)
+		encoded <- err
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	req.SetBasicAuth(c.Config.User, c.Config.Password)
-	resp, err := c.Transporter.HTTPClient.Do(req)
+	resp, err := c.Transporter.Do(ctx, common.Call{Service: "fax", Operation: "Send", Class: common.SendOperation}, req)
 	if err != nil {
-		return "", err
+		// closing the body stops the encoder, its error only wins if it failed on its own, e.g.
+		// because a document was too large
+		body.Close()
+		if encodeErr := <-encoded; encodeErr != nil && !errors.Is(encodeErr, io.ErrClosedPipe) {
+			return "", true, encodeErr
+		}
+		return "", false, err
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(common.HTTPStatusKey.Int(resp.StatusCode))
 
 	if err := statusToError(resp.StatusCode, resp.Body); err != nil {
-		return "", err
+		return "", isRejection(resp.StatusCode), err
 	}
 
 	type jobResp struct {
@@ -72,149 +168,138 @@ func (c *Client) Send(job Job) (jobID string, err error) {
 	var jobResponse jobResp
 
 	if err := json.NewDecoder(resp.Body).Decode(&jobResponse); err != nil {
-		return "", err
+		return "", false, err
 	}
 
-	return jobResponse.JobID, nil
+	span.SetAttributes(common.JobIDKey.String(jobResponse.JobID))
+	// jobs with documents which can't be counted locally aren't recorded, rather than too few pages
+	if pages != nil {
+		body.Close()
+		if <-encoded == nil && *pages >= 0 {
+			c.Transporter.Metrics.AddFaxPages(Estimation{PagesPerRecipient: *pages}.withJob(job).Pages)
+		}
+	}
+	return jobResponse.JobID, false, nil
 }
 
-// GetBulkReports It is possible to perform bulk operations on the status reports through a POST .
-// The maximum number of jobs per POST request is set to 1000.
+// GetBulkReports gets the reports of the given job ids from all datacenters, in the order of
+// jobIDs. Any number of job ids can be passed, they are requested in chunks of MaxBulkJobIDs.
+// Use FindReports to get the job ids without report, too.
 func (c *Client) GetBulkReports(jobIDs []string) ([]Report, error) {
-	bulkreq := bulkReportRequest{
-		Action: "GET",
-		JobIDs: jobIDs,
-	}
-
-	bulkBytes, err := json.Marshal(bulkreq)
-	if err != nil {
-		return nil, err
-	}
-
-	responses := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, bulkBytes, c.Config.CustomerNumber+"/fax/reports", http.MethodPost)
-	var allReports []Report
-
-	for _, resp := range responses {
-		defer resp.Body.Close()
-		if resp.StatusCode == 200 || resp.StatusCode == 404 {
-			var faxReports struct {
-				Reports []Report `json:"reports"`
-			}
-
-			if err := json.NewDecoder(resp.Body).Decode(&faxReports); err != nil {
-				return nil, err
-			}
-
-			allReports = append(allReports, faxReports.Reports...)
-		} else {
-			if err := statusToError(resp.StatusCode, resp.Body); err != nil {
-				return nil, err
-			}
-		}
-	}
+	return c.GetBulkReportsContext(context.Background(), jobIDs)
+}
 
-	return allReports, nil
+// GetBulkReportsContext is like GetBulkReports, but waits for the client side report limiter under the control of ctx.
+func (c *Client) GetBulkReportsContext(ctx context.Context, jobIDs []string) ([]Report, error) {
+	result, err := c.FindReportsContext(ctx, jobIDs)
+	return result.Reports, err
 }
 
-// Takes in a array of job ids and deletes all job reports which can befoudn with the gievn job ids, the response will contain a boolean which verifys if the reprot was delete or not.
+// DeleteBulkReports deletes the reports of the given job ids in all datacenters. It returns one
+// DeleteReport per job id, in the order of jobIDs, which tells whether and where the report was
+// deleted, or why not, e.g. NOT_FOUND. Any number of job ids can be passed, they are sent in
+// chunks of MaxBulkJobIDs in parallel. If some chunks fail, their job ids have the Reason
+// INTERNAL_ERROR and the results are returned together with the errors of the chunks.
 func (c *Client) DeleteBulkReports(jobIDs []string) ([]DeleteReport, error) {
-	bulkreq := bulkReportRequest{
-		Action: "DELETE",
-		JobIDs: jobIDs,
-	}
-
-	bulkBytes, err := json.Marshal(bulkreq)
-	if err != nil {
-		return nil, err
-	}
-
-	responses := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, bulkBytes, c.Config.CustomerNumber+"/fax/reports", http.MethodPost)
-	var allDeletedReports []DeleteReport
-
-	for _, resp := range responses {
-		defer resp.Body.Close()
-		if resp.StatusCode == 200 || resp.StatusCode == 404 {
-			var deleteReport struct {
-				Reports []DeleteReport `json:"reports,omitempty"`
-			}
+	return c.DeleteBulkReportsContext(context.Background(), jobIDs)
+}
 
-			if err := json.NewDecoder(resp.Body).Decode(&deleteReport); err != nil {
-				return nil, err
-			}
-			if deleteReport.Reports[0].Reason == "NOT_FOUND" {
-				continue
-			}
-			allDeletedReports = append(allDeletedReports, deleteReport.Reports...)
-		} else {
-			if err := statusToError(resp.StatusCode, resp.Body); err != nil {
-				return nil, err
+// DeleteBulkReportsContext is like DeleteBulkReports, but waits for the client side report limiter under the control of ctx.
+func (c *Client) DeleteBulkReportsContext(ctx context.Context, jobIDs []string) (deleted []DeleteReport, err error) {
+	ctx, span := c.startSpan(ctx, "DeleteBulkReports", common.JobCountKey.Int(len(jobIDs)))
+	defer func() { common.EndSpan(span, err) }()
+
+	jobIDs = uniqueIDs(jobIDs)
+	chunks := make([][]DeleteReport, (len(jobIDs)+MaxBulkJobIDs-1)/MaxBulkJobIDs)
+	errs := make([]error, len(chunks))
+	forChunks(jobIDs, func(i int, chunk []string) {
+		body, err := json.Marshal(bulkReportRequest{Action: "DELETE", JobIDs: chunk})
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		outcomes := c.fetchDeletes(ctx, "DeleteBulkReports", http.MethodPost, c.Config.CustomerNumber+"/fax/reports", body, false)
+		errs[i] = allFailed(outcomes)
+		chunks[i] = mergeDeletes(chunk, outcomes)
+	})
+
+	failed := 0
+	for i, chunk := range chunks {
+		if errs[i] != nil {
+			failed++
+			for j := range chunk {
+				chunk[j].Reason, chunk[j].Err = ReasonInternalError, errs[i]
 			}
 		}
+		deleted = append(deleted, chunk...)
 	}
-
-	return allDeletedReports, nil
+	if failed > 0 && failed == len(chunks) {
+		return nil, errors.Join(errs...)
+	}
+	return deleted, errors.Join(errs...)
 }
 
 // DeleteReports deletes up to 1000 status reports for completed fax jobs for the current account, starting from the
-// oldest ones. It returns the jobIds of deleted job reports.
+// oldest ones. It returns the jobIds of deleted job reports. If a datacenter fails, the reports
+// deleted in the other datacenters are returned together with the error.
 func (c *Client) DeleteReports() ([]DeleteReport, error) {
-	resp := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, []byte{}, c.Config.CustomerNumber+"/fax/reports", http.MethodDelete)
-
-	type deleteJobResponse struct {
-		Reports []DeleteReport `json:"reports,omitempty"`
-	}
-	var delReportResp deleteJobResponse
-
-	var deletedReports []DeleteReport
-
-	for _, x := range resp {
+	return c.DeleteReportsContext(context.Background())
+}
 
-		defer x.Body.Close()
-		if x.StatusCode == 200 || x.StatusCode == 404 {
-			defer x.Body.Close()
-			if err := json.NewDecoder(x.Body).Decode(&delReportResp); err != nil {
-				return nil, err
-			}
+// DeleteReportsContext is like DeleteReports, but waits for the client side report limiter under the control of ctx.
+func (c *Client) DeleteReportsContext(ctx context.Context) (deleted []DeleteReport, err error) {
+	ctx, span := c.startSpan(ctx, "DeleteReports")
+	defer func() { common.EndSpan(span, err) }()
 
-			deletedReports = append(deletedReports, delReportResp.Reports...)
-		} else {
-			if err := statusToError(x.StatusCode, x.Body); err != nil {
-				return nil, err
-			}
-		}
+	outcomes := c.fetchDeletes(ctx, "DeleteReports", http.MethodDelete, c.Config.CustomerNumber+"/fax/reports", []byte{}, false)
+	if err := allFailed(outcomes); err != nil {
+		return nil, err
 	}
-	return delReportResp.Reports, nil
+	return mergeDeletes(nil, outcomes), failedDatacenters(outcomes)
 }
 
-// DeleteReport deletes a Report for the given jobID.
+// DeleteReport deletes a Report for the given jobID in all datacenters. The Reason of the
+// returned DeleteReport is NOT_FOUND if no datacenter has the report. If the report wasn't
+// deleted and a datacenter failed, the error of the datacenter is returned, too.
 func (c *Client) DeleteReport(jobID string) (*DeleteReport, error) {
-	resp := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, []byte{}, c.Config.CustomerNumber+"/fax/reports/"+jobID, http.MethodDelete)
-	var deleteReport DeleteReport
-	for _, x := range resp {
-		type reports struct {
-			// Reports (required)
-			Reports []Report `json:"reports"`
-		}
-		defer x.Body.Close()
-		if x.StatusCode == 404 {
-			continue
-		}
-		if x.StatusCode == 200 {
-			defer x.Body.Close()
-			if err := json.NewDecoder(x.Body).Decode(&deleteReport); err != nil {
-				return nil, err
+	return c.DeleteReportContext(context.Background(), jobID)
+}
+
+// DeleteReportContext is like DeleteReport, but waits for the client side report limiter under the control of ctx.
+func (c *Client) DeleteReportContext(ctx context.Context, jobID string) (deleted *DeleteReport, err error) {
+	ctx, span := c.startSpan(ctx, "DeleteReport", common.JobIDKey.String(jobID))
+	defer func() { common.EndSpan(span, err) }()
+
+	outcomes := c.fetchDeletes(ctx, "DeleteReport", http.MethodDelete, c.Config.CustomerNumber+"/fax/reports/"+jobID, []byte{}, true)
+	if err := allFailed(outcomes); err != nil {
+		return nil, err
+	}
+	for _, dc := range outcomes {
+		for i := range dc.entries {
+			if dc.entries[i].JobID == "" {
+				dc.entries[i].JobID = jobID
 			}
-			return &deleteReport, nil
 		}
 	}
-
-	return &deleteReport, nil
+	report := mergeDeletes([]string{jobID}, outcomes)[0]
+	return &report, report.Err
 }
 
 // GetReport gets a Report for the given jobID, GetReport will not delete it
 // remotely, use DeleteReport after GetReport.
 func (c *Client) GetReport(jobID string) (*Report, error) {
-	resp := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, []byte{}, "/"+c.Config.CustomerNumber+"/fax/reports/"+jobID, http.MethodGet)
+	return c.GetReportContext(context.Background(), jobID)
+}
+
+// GetReportContext is like GetReport, but waits for the client side report limiter under the control of ctx.
+func (c *Client) GetReportContext(ctx context.Context, jobID string) (report *Report, err error) {
+	ctx, span := c.startSpan(ctx, "GetReport", common.JobIDKey.String(jobID))
+	defer func() { common.EndSpan(span, err) }()
+
+	resp, err := c.fetch(ctx, "GetReport", http.MethodGet, "/"+c.Config.CustomerNumber+"/fax/reports/"+jobID, []byte{})
+	if err != nil {
+		return nil, err
+	}
 	var faxReport Report
 	for _, x := range resp {
 		defer x.Body.Close()
@@ -226,6 +311,9 @@ func (c *Client) GetReport(jobID string) (*Report, error) {
 			if err := json.NewDecoder(x.Body).Decode(&faxReport); err != nil {
 				return nil, err
 			}
+			if finished, ok := faxReport.finishedAt(); ok && !finished.IsZero() {
+				c.Transporter.ObservePollingLag("fax", finished)
+			}
 			return &faxReport, nil
 		}
 	}
@@ -238,7 +326,18 @@ func (c *Client) GetReport(jobID string) (*Report, error) {
 // Important: The results are limited to the oldes 1000 entries. It is recommended to delete
 // the status reports after fetching them in order to retrieve the following ones.
 func (c *Client) GetReports() ([]Report, error) {
-	resp := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, []byte{}, c.Config.CustomerNumber+"/fax/reports", http.MethodGet)
+	return c.GetReportsContext(context.Background())
+}
+
+// GetReportsContext is like GetReports, but waits for the client side report limiter under the control of ctx.
+func (c *Client) GetReportsContext(ctx context.Context) (reports []Report, err error) {
+	ctx, span := c.startSpan(ctx, "GetReports")
+	defer func() { common.EndSpan(span, err) }()
+
+	resp, err := c.fetch(ctx, "GetReports", http.MethodGet, c.Config.CustomerNumber+"/fax/reports", []byte{})
+	if err != nil {
+		return nil, err
+	}
 
 	var faxReports []Report
 
@@ -265,3 +364,21 @@ func (c *Client) GetReports() ([]Report, error) {
 
 	return faxReports, nil
 }
+
+// startSpan starts the span of a client call.
+func (c *Client) startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.Transporter.StartSpan(ctx, "fax", operation, c.Config.Region.Region, attrs...)
+}
+
+// fetch sends a report request to all datacenters of the configured region.
+func (c *Client) fetch(ctx context.Context, operation string, method string, resource string, body []byte) ([]*http.Response, error) {
+	return c.Transporter.DoDatacenterFetchContext(ctx, common.DatacenterRequest{
+		Call:     common.Call{Service: "fax", Operation: operation, Class: common.ReportOperation},
+		Servers:  c.Config.Region.Servers,
+		Username: c.Config.User,
+		Password: c.Config.Password,
+		Body:     body,
+		Resource: resource,
+		Method:   method,
+	})
+}
@@ -3,16 +3,65 @@ package fax
 import (
 	"fmt"
 	"github.com/retarus/retarus-go/common"
+	"github.com/retarus/retarus-go/retarustest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
-func faxClientProvider() Client {
-	config := NewConfigFromEnv(common.Europe)
+func faxClientProvider(t *testing.T) (Client, *retarustest.Server) {
+	srv := retarustest.NewServer(retarustest.Options{})
+	t.Cleanup(srv.Close)
+	config := Config{User: srv.User, Password: srv.Password, CustomerNumber: srv.CustomerNumber, Region: srv.FaxRegion()}
 	client := NewClient(config)
+	return client, srv
+}
+
+// syntheticRegion is the region of sessions which were captured from retarustest instead of the
+// real service, its hosts can't be mistaken for production.
+var syntheticRegion = common.NewRegionURI(common.Europe, "https://faxws-ha.retarustest.invalid/rest/v1/",
+	[]string{"https://faxws.dc2.retarustest.invalid/rest/v1/", "https://faxws.dc1.retarustest.invalid/rest/v1/"})
+
+// cassetteClientProvider returns a client which replays testdata/<test name>.json with the
+// addresses of region. With retarus_record=1 the session is recorded against the real service
+// configured by NewConfigFromEnv instead. The test is skipped if nothing has been recorded yet,
+// and fails if the CI environment variable is set, so a missing recording doesn't go unnoticed
+// in CI.
+func cassetteClientProvider(t *testing.T, region common.RegionURI) Client {
+	path := filepath.Join("testdata", t.Name()+".json")
+	if os.Getenv("retarus_record") != "" {
+		config := NewConfigFromEnv(common.Europe)
+		cassette, err := common.NewCassette(path, common.Record, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cassette.Secrets[config.CustomerNumber] = "12345"
+		t.Cleanup(func() {
+			os.MkdirAll("testdata", 0o755)
+			if err := cassette.Save(); err != nil {
+				t.Error(err)
+			}
+		})
+		client := NewClient(config)
+		client.Transporter.HTTPClient.Transport = cassette
+		return client
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("no recorded session in %s, record it with retarus_record=1", path)
+		}
+		t.Skipf("no recorded session in %s, record it with retarus_record=1", path)
+	}
+	cassette, err := common.NewCassette(path, common.Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(Config{User: "user", Password: "password", CustomerNumber: "12345", Region: &region})
+	client.Transporter.HTTPClient.Transport = cassette
 	return client
 }
 
-func faxGeneratorCreator(amount int) []string {
+func faxGeneratorCreator(client Client, amount int) []string {
 	job := Job{
 		Recipients: []Recipient{
 			{
@@ -27,7 +76,6 @@ func faxGeneratorCreator(amount int) []string {
 		},
 	}
 	jobIds := []string{}
-	client := faxClientProvider()
 	for i := 1; i < amount+1; i++ {
 		res, _ := client.Send(job)
 		jobIds = append(jobIds, res)
@@ -50,7 +98,7 @@ func TestNormalFaxSend(t *testing.T) {
 			},
 		},
 	}
-	client := faxClientProvider()
+	client, _ := faxClientProvider(t)
 	res, err := client.Send(job)
 
 	if err != nil {
@@ -62,8 +110,8 @@ func TestNormalFaxSend(t *testing.T) {
 }
 
 func TestGetNormalFaxReport(t *testing.T) {
-	client := faxClientProvider()
-	jobIds := faxGeneratorCreator(1)
+	client, _ := faxClientProvider(t)
+	jobIds := faxGeneratorCreator(client, 1)
 
 	first := jobIds[0]
 	res, err := client.GetReport(first)
@@ -74,8 +122,8 @@ func TestGetNormalFaxReport(t *testing.T) {
 }
 
 func TestGetBulkReport(t *testing.T) {
-	client := faxClientProvider()
-	jobIds := faxGeneratorCreator(5)
+	client, _ := faxClientProvider(t)
+	jobIds := faxGeneratorCreator(client, 5)
 	res, err := client.GetBulkReports(jobIds)
 	if err != nil {
 		t.Errorf("Error shouldn't happen here: %s", err)
@@ -85,9 +133,24 @@ func TestGetBulkReport(t *testing.T) {
 
 	}
 }
+
+// TestGetBulkReportRecorded replays a synthetic session, which was captured from retarustest.
+// Record it against the sandbox and replay it with common.Europe to test the real service.
+func TestGetBulkReportRecorded(t *testing.T) {
+	client := cassetteClientProvider(t, syntheticRegion)
+	jobIds := faxGeneratorCreator(client, 5)
+	res, err := client.GetBulkReports(jobIds)
+	if err != nil {
+		t.Errorf("Error shouldn't happen here: %s", err)
+	}
+	if len(res) != 5 {
+		t.Errorf("Missing some reports: %d", len(res))
+	}
+}
+
 func TestDeleteReports(t *testing.T) {
-	client := faxClientProvider()
-	jobIds := faxGeneratorCreator(5)
+	client, _ := faxClientProvider(t)
+	jobIds := faxGeneratorCreator(client, 5)
 	res, err := client.DeleteBulkReports(jobIds)
 	if err != nil {
 		t.Errorf("Error shouldn't happen here: %s", err)
@@ -99,8 +162,8 @@ func TestDeleteReports(t *testing.T) {
 }
 
 func TestGetBulkFaxReport(t *testing.T) {
-	client := faxClientProvider()
-	jobIds := faxGeneratorCreator(5)
+	client, _ := faxClientProvider(t)
+	jobIds := faxGeneratorCreator(client, 5)
 	res, err := client.GetBulkReports(jobIds)
 	if err != nil {
 		t.Errorf("Error shouldn't happen here: %s", err)
@@ -12,8 +12,20 @@ type Config struct {
 	Password       string
 	CustomerNumber string
 	Region         *common.RegionURI
+	// MaxDocumentSize (optional) is the maximum size of a single document in bytes before base64
+	// encoding, default DefaultMaxDocumentSize.
+	MaxDocumentSize int64
+	// MaxJobSize (optional) is the maximum size of all documents of a job in bytes before base64
+	// encoding, default DefaultMaxJobSize.
+	MaxJobSize int64
 }
 
+// Default size limits of fax jobs, they are checked before and while a job is sent.
+const (
+	DefaultMaxDocumentSize int64 = 50 << 20
+	DefaultMaxJobSize      int64 = 100 << 20
+)
+
 // NewConfig initializes and returns a Config instance based on the provided parameters.
 //
 // Parameters:
@@ -0,0 +1,240 @@
+package fax
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnsupportedDocument is returned for documents whose file type can't be faxed.
+	ErrUnsupportedDocument = errors.New("unsupported document type")
+	// ErrEmptyDocument is returned for documents without content.
+	ErrEmptyDocument = errors.New("document is empty")
+)
+
+// maxDocumentName is the maximum length of Document.Name accepted by the API.
+const maxDocumentName = 32
+
+// fileTypes maps the file extensions set by the document constructors to their aliases, which are
+// kept if a given name already uses them.
+var fileTypes = map[string][]string{
+	"pdf":  {"pdf"},
+	"tif":  {"tif", "tiff"},
+	"png":  {"png"},
+	"jpg":  {"jpg", "jpeg"},
+	"gif":  {"gif"},
+	"bmp":  {"bmp"},
+	"rtf":  {"rtf"},
+	"doc":  {"doc", "xls", "ppt"},
+	"docx": {"docx"},
+	"xlsx": {"xlsx"},
+	"pptx": {"pptx"},
+	"odt":  {"odt"},
+	"ods":  {"ods"},
+	"odp":  {"odp"},
+	"html": {"html", "htm"},
+	"txt":  {"txt"},
+}
+
+// NewDocumentFromFile reads a file and returns it as a Document with inline data. The file type
+// is detected from the content, the name is derived from the file name.
+func NewDocumentFromFile(filePath string) (Document, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return Document{}, err
+	}
+	return NewDocumentFromBytes(filepath.Base(filePath), data)
+}
+
+// NewDocumentFromReader reads r to the end and returns the content as a Document with inline data.
+func NewDocumentFromReader(name string, r io.Reader) (Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Document{}, err
+	}
+	return NewDocumentFromBytes(name, data)
+}
+
+// NewDocumentFromBytes returns data as a Document with base64 encoded inline data. The file type
+// is detected from the content and must be one the fax service can render, the name is shortened
+// and cleaned to the characters allowed by the API and gets the extension of the detected type.
+// The Charset of text documents is detected, too.
+func NewDocumentFromBytes(name string, data []byte) (Document, error) {
+	if len(data) == 0 {
+		return Document{}, ErrEmptyDocument
+	}
+	ext, charset := DetectFileType(data)
+	if ext == "" {
+		return Document{}, fmt.Errorf("%w: %s", ErrUnsupportedDocument, name)
+	}
+	return Document{
+		Name:    documentName(name, ext),
+		Charset: charset,
+		Data:    base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// NewDocumentFromURL returns a Document which references the file at the given http(s) URL, it
+// is downloaded by Retarus. The file type can only be checked by the extension of the URL path.
+func NewDocumentFromURL(ref string) (Document, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return Document{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Document{}, fmt.Errorf("document reference must be a http(s) URL: %s", ref)
+	}
+	name := path.Base(u.Path)
+	ext := typeOfExtension(path.Ext(name))
+	if ext == "" {
+		return Document{}, fmt.Errorf("%w: %s", ErrUnsupportedDocument, ref)
+	}
+	return Document{Name: documentName(name, ext), Reference: ref}, nil
+}
+
+// DetectFileType returns the file extension of the given document content, e.g. "pdf", or an
+// empty string if the type isn't supported by the fax service. For text documents the detected
+// Charset is returned, too.
+func DetectFileType(data []byte) (string, Charset) {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF")):
+		return "pdf", ""
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return "tif", ""
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png", ""
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpg", ""
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif", ""
+	case bytes.HasPrefix(data, []byte("BM")) && len(data) > 14:
+		return "bmp", ""
+	case bytes.HasPrefix(data, []byte(`{\rtf`)):
+		return "rtf", ""
+	case bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")):
+		// legacy Office documents share the OLE container format
+		return "doc", ""
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return zipFileType(data), ""
+	}
+	return textFileType(data)
+}
+
+// zipFileType tells Office Open XML and OpenDocument files apart by their entries.
+func zipFileType(data []byte) string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+	for _, f := range r.File {
+		switch {
+		case strings.HasPrefix(f.Name, "word/"):
+			return "docx"
+		case strings.HasPrefix(f.Name, "xl/"):
+			return "xlsx"
+		case strings.HasPrefix(f.Name, "ppt/"):
+			return "pptx"
+		case f.Name == "mimetype":
+			rc, err := f.Open()
+			if err != nil {
+				return ""
+			}
+			mime, _ := io.ReadAll(io.LimitReader(rc, 100))
+			rc.Close()
+			switch string(mime) {
+			case "application/vnd.oasis.opendocument.text":
+				return "odt"
+			case "application/vnd.oasis.opendocument.spreadsheet":
+				return "ods"
+			case "application/vnd.oasis.opendocument.presentation":
+				return "odp"
+			}
+		}
+	}
+	return ""
+}
+
+// textFileType detects plain text and HTML documents and their charset, binary content is unsupported.
+func textFileType(data []byte) (string, Charset) {
+	var charset Charset
+	var text []byte
+	switch {
+	case bytes.HasPrefix(data, []byte("\xef\xbb\xbf")):
+		charset, text = UTF_8, data[3:]
+	case bytes.HasPrefix(data, []byte("\xff\xfe")):
+		charset, text = UTF_16LE, nil
+	case bytes.HasPrefix(data, []byte("\xfe\xff")):
+		charset, text = UTF_16BE, nil
+	case bytes.IndexByte(data, 0) >= 0 || !isText(data):
+		return "", ""
+	case utf8.Valid(data):
+		charset, text = UTF_8, data
+	default:
+		charset, text = WINDOWS_1252, data
+	}
+
+	head := strings.ToLower(string(text[:min(len(text), 512)]))
+	if strings.Contains(head, "<html") || strings.Contains(head, "<!doctype html") {
+		return "html", charset
+	}
+	return "txt", charset
+}
+
+// isText reports whether data contains no control characters besides whitespace.
+func isText(data []byte) bool {
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+func typeOfExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	for t, aliases := range fileTypes {
+		for _, alias := range aliases {
+			if alias == ext {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+// documentName returns a name accepted by the API: at most 32 characters of a-zA-Z0-9-_. ending
+// with an extension of the given file type.
+func documentName(name string, fileType string) string {
+	name = path.Base(filepath.ToSlash(name))
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if typeOfExtension(ext) != fileType {
+		// a wrong or unknown extension is kept as part of the name
+		base, ext = name, "."+fileType
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, base)
+	base = strings.Trim(base, "_.")
+	if base == "" {
+		base = "document"
+	}
+	if len(base)+len(ext) > maxDocumentName {
+		base = base[:maxDocumentName-len(ext)]
+	}
+	return base + ext
+}
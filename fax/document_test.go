@@ -0,0 +1,115 @@
+package fax
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func zipWith(t *testing.T, name string, content string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(content))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDetectFileType(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		ext     string
+		charset Charset
+	}{
+		{"pdf", []byte("%PDF-1.4\n"), "pdf", ""},
+		{"tiff", tiffWithPages(1), "tif", ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), "png", ""},
+		{"jpg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "jpg", ""},
+		{"doc", []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00"), "doc", ""},
+		{"docx", zipWith(t, "word/document.xml", "<w:document/>"), "docx", ""},
+		{"odt", zipWith(t, "mimetype", "application/vnd.oasis.opendocument.text"), "odt", ""},
+		{"zip", zipWith(t, "readme.md", "hello"), "", ""},
+		{"txt", []byte("Hello fax\r\n"), "txt", UTF_8},
+		{"latin1", []byte("Gr\xfc\xdfe"), "txt", WINDOWS_1252},
+		{"utf16", []byte("\xff\xfeH\x00i\x00"), "txt", UTF_16LE},
+		{"html", []byte("<!DOCTYPE html><html><body>Fax</body></html>"), "html", UTF_8},
+		{"binary", []byte{0x00, 0x01, 0x02, 0x03}, "", ""},
+	}
+	for _, tt := range tests {
+		ext, charset := DetectFileType(tt.data)
+		if ext != tt.ext || charset != tt.charset {
+			t.Errorf("%s: expected %q %q, got %q %q", tt.name, tt.ext, tt.charset, ext, charset)
+		}
+	}
+}
+
+func TestDocumentName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileType string
+		expected string
+	}{
+		{"Invoice-2017-01.pdf", "pdf", "Invoice-2017-01.pdf"},
+		{"scan.TIFF", "tif", "scan.TIFF"},
+		{"/var/spool/fax/Rechnung März 2024.pdf", "pdf", "Rechnung_M_rz_2024.pdf"},
+		{"report.pdf", "docx", "report.pdf.docx"},
+		{"a-very-long-document-name-with-many-characters.pdf", "pdf", "a-very-long-document-name-wi.pdf"},
+		{"", "txt", "document.txt"},
+	}
+	for _, tt := range tests {
+		if got := documentName(tt.name, tt.fileType); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.name, tt.expected, got)
+		}
+		if got := documentName(tt.name, tt.fileType); len(got) > maxDocumentName {
+			t.Errorf("%q: name %q is too long", tt.name, got)
+		}
+	}
+}
+
+func TestNewDocumentFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "letter to customer")
+	os.WriteFile(path, []byte("Dear customer,\n"), 0o644)
+
+	d, err := NewDocumentFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "letter_to_customer.txt" || d.Charset != UTF_8 {
+		t.Errorf("unexpected document %+v", d)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(d.Data); string(data) != "Dear customer,\n" {
+		t.Errorf("unexpected data %q", data)
+	}
+
+	if _, err := NewDocumentFromReader("empty.pdf", strings.NewReader("")); !errors.Is(err, ErrEmptyDocument) {
+		t.Errorf("expected ErrEmptyDocument, got %v", err)
+	}
+	if _, err := NewDocumentFromBytes("app.exe", []byte("MZ\x90\x00\x03")); !errors.Is(err, ErrUnsupportedDocument) {
+		t.Errorf("expected ErrUnsupportedDocument, got %v", err)
+	}
+}
+
+func TestNewDocumentFromURL(t *testing.T) {
+	d, err := NewDocumentFromURL("https://example.com/files/order%20form.pdf?version=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "order_form.pdf" || d.Reference == "" || d.Data != "" {
+		t.Errorf("unexpected document %+v", d)
+	}
+	if _, err := NewDocumentFromURL("https://example.com/download"); !errors.Is(err, ErrUnsupportedDocument) {
+		t.Errorf("expected ErrUnsupportedDocument, got %v", err)
+	}
+	if _, err := NewDocumentFromURL("file:///etc/passwd.txt"); err == nil {
+		t.Error("expected an error for a non http URL")
+	}
+}
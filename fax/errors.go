@@ -47,3 +47,8 @@ func statusToError(statusCode int, body io.Reader) error {
 
 	return fmt.Errorf("%w: %s", ErrUnknown, msgStr)
 }
+
+// isRejection reports whether a status code of a send means that the job wasn't created.
+func isRejection(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500
+}
@@ -0,0 +1,227 @@
+// Package faxtest provides a fake implementation of fax.API for tests.
+package faxtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/retarus/retarus-go/fax"
+)
+
+// ErrNotFound is returned for job IDs without a report.
+var ErrNotFound = errors.New("no report exists for the specified job ID")
+
+// Call is a single recorded call of a Recorder.
+type Call struct {
+	// Method is the name of the called method, the Context variants are recorded under the plain name.
+	Method string
+	// JobIDs are the job IDs passed to or returned by the call.
+	JobIDs []string
+	// Job is the sent job, only set for Send.
+	Job *fax.Job
+}
+
+// Recorder is a fake fax.API which records every call. Sent jobs get consecutive job IDs and a
+// report without recipient status, SetReport replaces it, e.g. with a finished report.
+// Note: To create a new instance of Recorder, use the NewRecorder function.
+type Recorder struct {
+	mu      sync.Mutex
+	calls   []Call
+	reports map[string]*fax.Report
+	order   []string
+	err     error
+	sent    int
+}
+
+var _ fax.API = (*Recorder)(nil)
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{reports: map[string]*fax.Report{}}
+}
+
+// FailWith lets every following call return err, nil restores normal operation.
+func (r *Recorder) FailWith(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// SetReport sets the report of a job.
+func (r *Recorder) SetReport(report fax.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reports[report.JobID]; !ok {
+		r.order = append(r.order, report.JobID)
+	}
+	r.reports[report.JobID] = &report
+}
+
+// Calls returns all recorded calls in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call{}, r.calls...)
+}
+
+// Jobs returns all sent jobs in order.
+func (r *Recorder) Jobs() []fax.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := []fax.Job{}
+	for _, c := range r.calls {
+		if c.Job != nil {
+			jobs = append(jobs, *c.Job)
+		}
+	}
+	return jobs
+}
+
+func (r *Recorder) record(c Call) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, c)
+	return r.err
+}
+
+func (r *Recorder) Send(job fax.Job) (string, error) {
+	return r.SendContext(context.Background(), job)
+}
+
+func (r *Recorder) SendContext(ctx context.Context, job fax.Job) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.sent++
+	jobID := fmt.Sprintf("FJ%d", r.sent)
+	r.mu.Unlock()
+	if err := r.record(Call{Method: "Send", JobIDs: []string{jobID}, Job: &job}); err != nil {
+		return "", err
+	}
+	report := fax.Report{JobID: jobID}
+	if job.Reference != nil {
+		ref := *job.Reference
+		report.Reference = &ref
+	}
+	r.SetReport(report)
+	return jobID, nil
+}
+
+func (r *Recorder) GetReport(jobID string) (*fax.Report, error) {
+	return r.GetReportContext(context.Background(), jobID)
+}
+
+func (r *Recorder) GetReportContext(ctx context.Context, jobID string) (*fax.Report, error) {
+	if err := r.record(Call{Method: "GetReport", JobIDs: []string{jobID}}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if report, ok := r.reports[jobID]; ok {
+		copied := *report
+		return &copied, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *Recorder) GetReports() ([]fax.Report, error) {
+	return r.GetReportsContext(context.Background())
+}
+
+func (r *Recorder) GetReportsContext(ctx context.Context) ([]fax.Report, error) {
+	if err := r.record(Call{Method: "GetReports"}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.collect(r.order), nil
+}
+
+func (r *Recorder) GetBulkReports(jobIDs []string) ([]fax.Report, error) {
+	return r.GetBulkReportsContext(context.Background(), jobIDs)
+}
+
+func (r *Recorder) GetBulkReportsContext(ctx context.Context, jobIDs []string) ([]fax.Report, error) {
+	if err := r.record(Call{Method: "GetBulkReports", JobIDs: jobIDs}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.collect(jobIDs), nil
+}
+
+func (r *Recorder) DeleteReport(jobID string) (*fax.DeleteReport, error) {
+	return r.DeleteReportContext(context.Background(), jobID)
+}
+
+func (r *Recorder) DeleteReportContext(ctx context.Context, jobID string) (*fax.DeleteReport, error) {
+	if err := r.record(Call{Method: "DeleteReport", JobIDs: []string{jobID}}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := r.delete(jobID)
+	return &deleted, nil
+}
+
+func (r *Recorder) DeleteReports() ([]fax.DeleteReport, error) {
+	return r.DeleteReportsContext(context.Background())
+}
+
+func (r *Recorder) DeleteReportsContext(ctx context.Context) ([]fax.DeleteReport, error) {
+	if err := r.record(Call{Method: "DeleteReports"}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := []fax.DeleteReport{}
+	for _, id := range append([]string{}, r.order...) {
+		deleted = append(deleted, r.delete(id))
+	}
+	return deleted, nil
+}
+
+func (r *Recorder) DeleteBulkReports(jobIDs []string) ([]fax.DeleteReport, error) {
+	return r.DeleteBulkReportsContext(context.Background(), jobIDs)
+}
+
+func (r *Recorder) DeleteBulkReportsContext(ctx context.Context, jobIDs []string) ([]fax.DeleteReport, error) {
+	if err := r.record(Call{Method: "DeleteBulkReports", JobIDs: jobIDs}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := []fax.DeleteReport{}
+	for _, id := range jobIDs {
+		deleted = append(deleted, r.delete(id))
+	}
+	return deleted, nil
+}
+
+// collect returns the reports of the given job IDs, unknown job IDs are left out.
+func (r *Recorder) collect(jobIDs []string) []fax.Report {
+	reports := []fax.Report{}
+	for _, id := range jobIDs {
+		if report, ok := r.reports[id]; ok {
+			reports = append(reports, *report)
+		}
+	}
+	return reports
+}
+
+func (r *Recorder) delete(jobID string) fax.DeleteReport {
+	if _, ok := r.reports[jobID]; !ok {
+		return fax.DeleteReport{JobID: jobID, Deleted: false, Reason: "NOT_FOUND"}
+	}
+	delete(r.reports, jobID)
+	for i, id := range r.order {
+		if id == jobID {
+			r.order = append(r.order[:i:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return fax.DeleteReport{JobID: jobID, Deleted: true}
+}
@@ -0,0 +1,120 @@
+package faxtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/retarus/retarus-go/fax"
+)
+
+func TestRecorderSendsAndReports(t *testing.T) {
+	r := NewRecorder()
+	job := fax.Job{
+		Recipients: []fax.Recipient{fax.NewRecipient("+4989000001", nil, nil)},
+		Reference:  &fax.Reference{CustomerDefinedID: "order-1"},
+	}
+	first, err := r.Send(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := r.SendContext(context.Background(), job)
+	if first != "FJ1" || second != "FJ2" {
+		t.Fatalf("expected consecutive job IDs, got %s and %s", first, second)
+	}
+
+	report, err := r.GetReport(first)
+	if err != nil || report.JobID != first || report.Reference == nil || report.Reference.CustomerDefinedID != "order-1" {
+		t.Fatalf("unexpected report %+v, %v", report, err)
+	}
+	r.SetReport(fax.Report{JobID: first, Pages: 2})
+	if report, _ := r.GetReport(first); report.Pages != 2 {
+		t.Errorf("expected the report set by the test, got %+v", report)
+	}
+	if _, err := r.GetReport("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if reports, _ := r.GetReports(); len(reports) != 2 || reports[0].JobID != first {
+		t.Errorf("expected the reports in send order, got %+v", reports)
+	}
+	if reports, _ := r.GetBulkReports([]string{second, "unknown"}); len(reports) != 1 || reports[0].JobID != second {
+		t.Errorf("expected only the known report, got %+v", reports)
+	}
+	if jobs := r.Jobs(); len(jobs) != 2 || jobs[0].Reference.CustomerDefinedID != "order-1" {
+		t.Errorf("unexpected sent jobs %+v", jobs)
+	}
+}
+
+func TestRecorderDeletes(t *testing.T) {
+	r := NewRecorder()
+	for i := 0; i < 3; i++ {
+		r.Send(fax.Job{})
+	}
+
+	if deleted, _ := r.DeleteReport("FJ2"); !deleted.Deleted {
+		t.Errorf("expected the report to be deleted, got %+v", deleted)
+	}
+	deleted, _ := r.DeleteBulkReports([]string{"FJ1", "FJ2"})
+	if len(deleted) != 2 || !deleted[0].Deleted || deleted[1].Deleted || deleted[1].Reason != "NOT_FOUND" {
+		t.Errorf("unexpected bulk delete %+v", deleted)
+	}
+	if deleted, _ := r.DeleteReports(); len(deleted) != 1 || deleted[0].JobID != "FJ3" {
+		t.Errorf("expected the remaining report to be deleted, got %+v", deleted)
+	}
+	if reports, _ := r.GetReports(); len(reports) != 0 {
+		t.Errorf("expected no reports left, got %+v", reports)
+	}
+}
+
+func TestRecorderRecordsCallsAndFailures(t *testing.T) {
+	r := NewRecorder()
+	r.Send(fax.Job{})
+	r.FailWith(fax.ErrServiceUnavailable)
+	if _, err := r.GetReportContext(context.Background(), "FJ1"); !errors.Is(err, fax.ErrServiceUnavailable) {
+		t.Errorf("expected the configured error, got %v", err)
+	}
+	if _, err := r.Send(fax.Job{}); !errors.Is(err, fax.ErrServiceUnavailable) {
+		t.Errorf("expected the configured error, got %v", err)
+	}
+	r.FailWith(nil)
+	r.DeleteBulkReports([]string{"FJ1"})
+
+	calls := r.Calls()
+	methods := []string{"Send", "GetReport", "Send", "DeleteBulkReports"}
+	if len(calls) != len(methods) {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+	for i, m := range methods {
+		if calls[i].Method != m {
+			t.Errorf("call %d: expected %s, got %s", i, m, calls[i].Method)
+		}
+	}
+	if calls[3].JobIDs[0] != "FJ1" {
+		t.Errorf("expected the job IDs of the call, got %v", calls[3].JobIDs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.SendContext(ctx, fax.Job{}); !errors.Is(err, context.Canceled) || len(r.Calls()) != len(methods) {
+		t.Errorf("expected a cancelled send not to be recorded, got %v", err)
+	}
+}
+
+func TestRecorderBroadcast(t *testing.T) {
+	r := NewRecorder()
+	recipients := []fax.Recipient{
+		fax.NewRecipient("+4989000001", nil, nil),
+		fax.NewRecipient("+4989000002", nil, nil),
+		fax.NewRecipient("+4989000003", nil, nil),
+	}
+	manifest, err := fax.Broadcast(context.Background(), r, fax.Job{}, recipients, fax.BroadcastOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := r.Jobs(); len(jobs) != 2 || len(jobs[0].Recipients)+len(jobs[1].Recipients) != 3 {
+		t.Errorf("expected two batches, got %+v", jobs)
+	}
+	if _, ok := manifest.JobID("+4989000003"); !ok {
+		t.Error("expected every recipient to have a job ID")
+	}
+}
@@ -0,0 +1,252 @@
+package fax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+// ErrIteratorDone is returned by ReportIterator.Next when there are no more reports.
+var ErrIteratorDone = errors.New("no more reports")
+
+// ReportQuery filters and pages the reports of a ReportIterator, the zero value returns all
+// reports of the first page.
+type ReportQuery struct {
+	// From and To (optional) keep the reports whose last recipient finished in [From, To).
+	// Finished reports without any SentTS, e.g. because every recipient failed before dialing,
+	// have no time and are kept, too.
+	From, To time.Time
+	// Status (optional) keeps the reports with at least one recipient in this status, e.g. "OK".
+	Status string
+	// CustomerReference (optional) keeps the reports with this Reference.CustomerDefinedID.
+	CustomerReference string
+	// Consume deletes the returned reports page by page, so the following reports can be fetched.
+	// The service only lists the oldest 1000 reports per datacenter, without Consume the iterator
+	// ends after them. Reports are deleted when the next page is fetched, so reports returned last
+	// before the iteration is stopped are kept. Reports which don't match the filter are never
+	// deleted, the iteration ends once a page has no new reports.
+	Consume bool
+}
+
+func (q ReportQuery) match(r Report) bool {
+	if q.CustomerReference != "" && (r.Reference == nil || r.Reference.CustomerDefinedID != q.CustomerReference) {
+		return false
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		finished, ok := r.finishedAt()
+		if !ok {
+			return false
+		}
+		if !finished.IsZero() && (finished.Before(q.From) || (!q.To.IsZero() && !finished.Before(q.To))) {
+			return false
+		}
+	}
+	if q.Status != "" {
+		for _, rs := range r.RecipientStatus {
+			if strings.EqualFold(rs.Status, q.Status) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ReportIterator pages through the reports of all datacenters without decoding them into a slice
+// at once. The page of every datacenter is read into memory when it is fetched, so the caller can
+// take its time with each report without running into the timeout of the HTTP client. Reports
+// found in several datacenters are returned once.
+// Note: To create a new instance of ReportIterator, use the Client.Reports method.
+type ReportIterator struct {
+	client *Client
+	query  ReportQuery
+
+	pages    []page
+	dec      *json.Decoder
+	seen     map[string]bool
+	returned []string
+	fresh    int
+	fetched  bool
+	err      error
+}
+
+// page is the response body of a datacenter.
+type page struct {
+	status int
+	body   *bytes.Reader
+}
+
+// Reports returns an iterator over the reports of this account which match the query.
+//
+//	it := client.Reports(fax.ReportQuery{Status: "OK"})
+//	defer it.Close()
+//	for {
+//		report, err := it.Next(ctx)
+//		if err == fax.ErrIteratorDone {
+//			break
+//		}
+//		...
+//	}
+func (c *Client) Reports(query ReportQuery) *ReportIterator {
+	return &ReportIterator{client: c, query: query, seen: map[string]bool{}}
+}
+
+// EachReport calls fn for every report which matches the query, until fn returns an error.
+func (c *Client) EachReport(ctx context.Context, query ReportQuery, fn func(Report) error) error {
+	it := c.Reports(query)
+	defer it.Close()
+	for {
+		report, err := it.Next(ctx)
+		if err == ErrIteratorDone {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(*report); err != nil {
+			return err
+		}
+	}
+}
+
+// Next returns the next report, or ErrIteratorDone after the last one.
+func (it *ReportIterator) Next(ctx context.Context) (*Report, error) {
+	for it.err == nil {
+		report, ok, err := it.decode()
+		if err != nil {
+			it.fail(err)
+			break
+		}
+		if !ok {
+			if err := it.fetchPage(ctx); err != nil {
+				it.fail(err)
+			}
+			continue
+		}
+		if it.seen[report.JobID] {
+			continue
+		}
+		it.seen[report.JobID] = true
+		it.fresh++
+		if !it.query.match(report) {
+			continue
+		}
+		if it.query.Consume {
+			it.returned = append(it.returned, report.JobID)
+		}
+		return &report, nil
+	}
+	return nil, it.err
+}
+
+// Close releases the current page. It should be called if the iteration is stopped before Next
+// returned ErrIteratorDone.
+func (it *ReportIterator) Close() error {
+	it.pages, it.dec = nil, nil
+	return nil
+}
+
+func (it *ReportIterator) fail(err error) {
+	it.Close()
+	it.err = err
+}
+
+// fetchPage deletes the reports returned from the previous page, if the query consumes them, and
+// fetches the next page from all datacenters.
+func (it *ReportIterator) fetchPage(ctx context.Context) (err error) {
+	if it.fetched && (!it.query.Consume || it.fresh == 0) {
+		return ErrIteratorDone
+	}
+	c := it.client
+	if len(it.returned) > 0 {
+		if _, err := c.DeleteBulkReportsContext(ctx, it.returned); err != nil {
+			return err
+		}
+		it.returned = nil
+	}
+
+	ctx, span := c.startSpan(ctx, "GetReports")
+	defer func() { common.EndSpan(span, err) }()
+	responses, err := c.fetch(ctx, "GetReports", http.MethodGet, c.Config.CustomerNumber+"/fax/reports", []byte{})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, res := range responses {
+			res.Body.Close()
+		}
+	}()
+	it.pages, it.fetched, it.fresh = nil, true, 0
+	for _, res := range responses {
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+			return statusToError(res.StatusCode, res.Body)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		it.pages = append(it.pages, page{status: res.StatusCode, body: bytes.NewReader(body)})
+	}
+	return nil
+}
+
+// decode returns the next report of the current page, ok is false once the page is read.
+func (it *ReportIterator) decode() (report Report, ok bool, err error) {
+	for len(it.pages) > 0 {
+		p := it.pages[0]
+		if it.dec == nil {
+			it.dec = json.NewDecoder(p.body)
+			found, err := seekArray(it.dec, "reports")
+			if err != nil && p.status == http.StatusOK {
+				return Report{}, false, err
+			}
+			if !found {
+				it.next()
+				continue
+			}
+		}
+		if !it.dec.More() {
+			it.next()
+			continue
+		}
+		if err := it.dec.Decode(&report); err != nil {
+			return Report{}, false, err
+		}
+		return report, true, nil
+	}
+	return Report{}, false, nil
+}
+
+// next continues with the page of the next datacenter.
+func (it *ReportIterator) next() {
+	it.pages, it.dec = it.pages[1:], nil
+}
+
+// seekArray reads the tokens of a JSON object up to the start of the array under key.
+func seekArray(dec *json.Decoder, key string) (bool, error) {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		if tok == key {
+			tok, err := dec.Token()
+			return tok == json.Delim('['), err
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,121 @@
+package fax
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+func collectReports(t *testing.T, client Client, query ReportQuery) []Report {
+	reports := []Report{}
+	err := client.EachReport(context.Background(), query, func(r Report) error {
+		reports = append(reports, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reports
+}
+
+func TestReportIterator(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	faxGeneratorCreator(client, 4)
+	referenced := Job{
+		Reference:  &Reference{CustomerDefinedID: "notice-42"},
+		Recipients: []Recipient{{Number: "+4989000000000"}},
+		Documents:  []Document{{Name: "a.txt", Data: "dGVzdGZheAo="}},
+	}
+	referencedID, err := client.Send(referenced)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reports := collectReports(t, client, ReportQuery{}); len(reports) != 0 {
+		t.Errorf("pending jobs shouldn't be listed, got %d reports", len(reports))
+	}
+	srv.Advance(time.Minute)
+
+	if reports := collectReports(t, client, ReportQuery{}); len(reports) != 5 {
+		t.Errorf("expected 5 reports, got %d", len(reports))
+	}
+	reports := collectReports(t, client, ReportQuery{CustomerReference: "notice-42", Status: "ok"})
+	if len(reports) != 1 || reports[0].JobID != referencedID {
+		t.Errorf("expected the referenced report only, got %v", reports)
+	}
+	if reports := collectReports(t, client, ReportQuery{From: srv.Now()}); len(reports) != 0 {
+		t.Errorf("all reports finished before now, got %d", len(reports))
+	}
+	if reports := collectReports(t, client, ReportQuery{Status: "FAILED"}); len(reports) != 0 {
+		t.Errorf("no report has failed recipients, got %d", len(reports))
+	}
+}
+
+func TestReportIteratorSlowConsumer(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	// the pages have to be larger than the buffers of the transport
+	faxGeneratorCreator(client, 200)
+	srv.Advance(time.Minute)
+	client.Transporter.HTTPClient.Timeout = 100 * time.Millisecond
+
+	count := 0
+	err := client.EachReport(context.Background(), ReportQuery{}, func(r Report) error {
+		count++
+		if count == 1 {
+			time.Sleep(150 * time.Millisecond)
+		}
+		return nil
+	})
+	if err != nil || count != 200 {
+		t.Errorf("expected 200 reports without a timeout, got %d, %v", count, err)
+	}
+}
+
+func TestReportIteratorConsume(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	faxGeneratorCreator(client, 6)
+	srv.Advance(time.Minute)
+
+	if reports := collectReports(t, client, ReportQuery{Consume: true}); len(reports) != 6 {
+		t.Errorf("expected 6 reports, got %d", len(reports))
+	}
+	if reports := collectReports(t, client, ReportQuery{}); len(reports) != 0 {
+		t.Errorf("consumed reports should be deleted, %d are left", len(reports))
+	}
+}
+
+func TestReportIteratorRemovesDuplicates(t *testing.T) {
+	servers := []string{}
+	for i := 0; i < 3; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reports := `{"jobId":"FJ1","recipientStatus":[],"pages":1},{"jobId":"FJ2","recipientStatus":[],"pages":1}`
+			fmt.Fprintf(w, `{"meta":{"count":2},"reports":[%s]}`, reports)
+		}))
+		defer server.Close()
+		servers = append(servers, server.URL+"/")
+	}
+	region := common.NewRegionURI(common.Europe, servers[0], servers)
+	client := NewClient(Config{User: "user", Password: "password", CustomerNumber: "12345", Region: &region})
+
+	it := client.Reports(ReportQuery{})
+	defer it.Close()
+	ids := []string{}
+	for {
+		report, err := it.Next(context.Background())
+		if err == ErrIteratorDone {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, report.JobID)
+	}
+	if len(ids) != 2 || ids[0] != "FJ1" || ids[1] != "FJ2" {
+		t.Errorf("expected FJ1 and FJ2 once, got %v", ids)
+	}
+}
@@ -1,8 +1,12 @@
 package fax
 
 import (
-	"fmt"
+	"encoding/json"
+	"io"
+	"strings"
 	"time"
+
+	"github.com/retarus/retarus-go/common"
 )
 
 // Job is a Faxjob specified in 4.5. FaxJobRequest.
@@ -120,6 +124,9 @@ type Document struct {
 	// Data is a base64 string with data, if no reference is provided. If both
 	// are provided, the reference data (see above) is used.
 	Data string `json:"data,omitempty"`
+	// Content (optional) is read and base64 encoded straight into the request while the job is
+	// sent, instead of Data, so large documents aren't held in memory. A reader can only be sent once.
+	Content io.Reader `json:"-"`
 }
 
 // TransportOptions contains information on the transmission of the fax.
@@ -282,19 +289,15 @@ const (
 	OAUTH2      AuthMethod = "OAUTH2"
 )
 
-type ISO8601Time time.Time
-
-func (t ISO8601Time) MarshalJSON() ([]byte, error) {
-	stamp := fmt.Sprintf("\"%s\"", time.Time(t).Format("2006-01-02T15:04:05.999-0700"))
-
-	return []byte(stamp), nil
-}
+// ISO8601Time is the timestamp type of the jobs and reports, an alias of common.ISO8601Time
+// which keeps code written against earlier versions of this package compiling.
+type ISO8601Time = common.ISO8601Time
 
 // StatusReportOptions settings for the status report. Consists of reportPurgeTs and reportMail.
 type StatusReportOptions struct {
-	// ReportPurgeTS (required) Not currently valid. The date after which the status report is
+	// ReportPurgeTS (optional) Not currently valid. The date after which the status report is
 	// no longer available. In ISO 8601 format Example : "2018-11-03T20:14:37.098+02:00"
-	ReportPurgeTS ISO8601Time `json:"reportPurgeTs"`
+	ReportPurgeTS *common.ISO8601Time `json:"reportPurgeTs,omitempty"`
 	// ReportMail (optional)
 	ReportMail *ReportMail `json:"reportMail,omitempty"`
 	// HTTPStatusPush (optional)
@@ -309,7 +312,7 @@ type JobValid struct {
 	// Job Expiration error. Example values are "Z" for UTC or
 	// -05:00 for EST.
 	// By default jobs are immediately valid.
-	Start string `json:"start,omitempty"`
+	Start *common.ISO8601Time `json:"start,omitempty"`
 	// End (optional) of validity for the job (in ISO 8601 format). Please note
 	// that also durations are supported; the following values are all
 	// valid expiration times:
@@ -317,7 +320,47 @@ type JobValid struct {
 	// moment specified)
 	// • PT80M (Expiration set to now + 80 minutes)
 	// By default jobs expire one month after they begin being valid.
-	End string `json:"end,omitempty"`
+	End *common.ISO8601Time `json:"end,omitempty"`
+	// EndAfter (optional) is sent as the duration End, e.g. PT80M, if End isn't set.
+	EndAfter time.Duration `json:"-"`
+}
+
+type jobValid struct {
+	Start *common.ISO8601Time `json:"start,omitempty"`
+	End   json.RawMessage     `json:"end,omitempty"`
+}
+
+func (v JobValid) MarshalJSON() ([]byte, error) {
+	raw := jobValid{Start: v.Start}
+	var err error
+	switch {
+	case v.End != nil:
+		raw.End, err = json.Marshal(v.End)
+	case v.EndAfter != 0:
+		raw.End, err = json.Marshal(common.FormatISO8601Duration(v.EndAfter))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON decodes End as a timestamp, or as EndAfter if it is a duration.
+func (v *JobValid) UnmarshalJSON(data []byte) error {
+	var raw jobValid
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*v = JobValid{Start: raw.Start}
+	if len(raw.End) == 0 {
+		return nil
+	}
+	var end string
+	if err := json.Unmarshal(raw.End, &end); err == nil && strings.HasPrefix(strings.TrimPrefix(end, "-"), "P") {
+		v.EndAfter, err = common.ParseISO8601Duration(end)
+		return err
+	}
+	return json.Unmarshal(raw.End, &v.End)
 }
 
 // Meta information about the request.
@@ -327,5 +370,5 @@ type Meta struct {
 	// JobValid (required) contains the valid start/end of a fax job (in ISO
 	// 8601 format). If this data is not defined correctly,
 	// you will receive a Job Expiration error (HTTP status code 400)
-	JobValid JobValid `json:"jobValid,omitempty"`
+	JobValid *JobValid `json:"jobValid,omitempty"`
 }
@@ -0,0 +1,49 @@
+package fax
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+func TestJobValidRoundTrip(t *testing.T) {
+	start := time.Date(2018, 10, 11, 15, 50, 21, 372e6, time.UTC)
+	for _, tc := range []struct {
+		valid JobValid
+		json  string
+	}{
+		{JobValid{}, `{}`},
+		{JobValid{Start: common.NewISO8601Time(start)}, `{"start":"2018-10-11T15:50:21.372Z"}`},
+		{JobValid{End: common.NewISO8601Time(start)}, `{"end":"2018-10-11T15:50:21.372Z"}`},
+		{JobValid{EndAfter: 80 * time.Minute}, `{"end":"PT1H20M"}`},
+	} {
+		data, err := json.Marshal(tc.valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.json {
+			t.Errorf("expected %s, got %s", tc.json, data)
+		}
+		var decoded JobValid
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := json.Marshal(decoded); string(again) != tc.json {
+			t.Errorf("round trip changed %s to %s", tc.json, again)
+		}
+	}
+
+	var decoded JobValid
+	if err := json.Unmarshal([]byte(`{"end":"PT80M"}`), &decoded); err != nil || decoded.EndAfter != 80*time.Minute {
+		t.Errorf("expected the duration of PT80M, got %+v %v", decoded, err)
+	}
+}
+
+func TestStatusReportOptionsOmitsPurgeTimestamp(t *testing.T) {
+	data, _ := json.Marshal(StatusReportOptions{})
+	if string(data) != `{}` {
+		t.Errorf("an unset ReportPurgeTS shouldn't be sent, got %s", data)
+	}
+}
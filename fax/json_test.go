@@ -0,0 +1,69 @@
+package fax
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/internal/jsontest"
+)
+
+func TestJobRoundTrip(t *testing.T) {
+	var job Job
+	jsontest.AssertRoundTrip(t, "job.json", &job)
+	if job.Meta.JobValid.EndAfter != 80*time.Minute {
+		t.Errorf("expected the validity to end after 80 minutes, got %+v", job.Meta.JobValid)
+	}
+	if _, offset := job.StatusReportOptions.ReportPurgeTS.Zone(); offset != 2*60*60 {
+		t.Errorf("the offset of the purge timestamp got lost: %v", job.StatusReportOptions.ReportPurgeTS)
+	}
+}
+
+func TestReportRoundTrip(t *testing.T) {
+	var report Report
+	jsontest.AssertRoundTrip(t, "report.json", &report)
+	if report.RecipientStatus[1].SentTS != nil {
+		t.Errorf("the pending recipient shouldn't have a sent timestamp, got %v", report.RecipientStatus[1].SentTS)
+	}
+	finished, ok := report.finishedAt()
+	if ok {
+		t.Errorf("a report with a pending recipient isn't finished, got %v", finished)
+	}
+}
+
+func TestEmptyTypesHaveNoZeroValues(t *testing.T) {
+	for _, v := range []any{Job{Recipients: []Recipient{}}, Meta{}, StatusReportOptions{}, RecipientStatus{}} {
+		data, _ := json.Marshal(v)
+		var fields map[string]any
+		json.Unmarshal(data, &fields)
+		for key, value := range fields {
+			if value == nil || reflect.DeepEqual(value, map[string]any{}) {
+				t.Errorf("%T encodes the unset %s as %v", v, key, value)
+			}
+		}
+	}
+}
+
+func TestDeleteReportDecoding(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "delete_reports.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Reports []DeleteReport `json:"reports"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	want := []DeleteReport{
+		{JobID: "FJ8D8DUQZ6MN2JMXE2TEI4", Deleted: true},
+		{JobID: "FJ8D8DUQZ6MN2JMXE2TEI5", Deleted: true},
+		{JobID: "FJ8D8DUQZ6MN2JMXE2TEI6", Reason: ReasonNotFound},
+	}
+	if !reflect.DeepEqual(list.Reports, want) {
+		t.Errorf("expected %+v, got %+v", want, list.Reports)
+	}
+}
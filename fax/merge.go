@@ -0,0 +1,165 @@
+package fax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+// Reasons of a DeleteReport which wasn't deleted.
+const (
+	// ReasonNotFound means no datacenter has a report for the job.
+	ReasonNotFound = "NOT_FOUND"
+	// ReasonInternalError means the report couldn't be deleted, DeleteReport.Err holds the cause
+	// if a datacenter failed on the client side, e.g. because it wasn't reachable.
+	ReasonInternalError = "INTERNAL_ERROR"
+)
+
+// deleteEntry is a DeleteReport as returned by the API, where an absent deleted flag means the
+// report was deleted.
+type deleteEntry struct {
+	JobID   string `json:"jobId"`
+	Deleted *bool  `json:"deleted"`
+	Reason  string `json:"reason"`
+}
+
+func (e deleteEntry) deleted() bool {
+	return e.Deleted == nil || *e.Deleted
+}
+
+// datacenterDeletes is the outcome of a delete request in a single datacenter.
+type datacenterDeletes struct {
+	server  string
+	entries []deleteEntry
+	err     error
+}
+
+// fetchDeletes sends a delete request to all datacenters and decodes their answers. A 404 answer
+// is a datacenter without the report, every other error is kept per datacenter.
+func (c *Client) fetchDeletes(ctx context.Context, operation string, method string, resource string, body []byte, single bool) []datacenterDeletes {
+	results := c.Transporter.FetchDatacenters(ctx, common.DatacenterRequest{
+		Call:     common.Call{Service: "fax", Operation: operation, Class: common.ReportOperation},
+		Servers:  c.Config.Region.Servers,
+		Username: c.Config.User,
+		Password: c.Config.Password,
+		Body:     body,
+		Resource: resource,
+		Method:   method,
+	})
+	outcomes := make([]datacenterDeletes, len(results))
+	for i, res := range results {
+		outcomes[i] = datacenterDeletes{server: res.Server, err: res.Err}
+		if res.Err != nil {
+			continue
+		}
+		outcomes[i].entries, outcomes[i].err = decodeDeletes(res.Response, single)
+	}
+	return outcomes
+}
+
+func decodeDeletes(res *http.Response, single bool) ([]deleteEntry, error) {
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		io.Copy(io.Discard, res.Body)
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, statusToError(res.StatusCode, res.Body)
+	}
+	if single {
+		var entry deleteEntry
+		if err := json.NewDecoder(res.Body).Decode(&entry); err != nil {
+			return nil, err
+		}
+		return []deleteEntry{entry}, nil
+	}
+	var list struct {
+		Reports []deleteEntry `json:"reports"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Reports, nil
+}
+
+// mergeDeletes returns one DeleteReport per job ID, in the order of jobIDs. A report is deleted
+// if any datacenter deleted it, NOT_FOUND if every datacenter answered without it and
+// INTERNAL_ERROR otherwise. Job IDs which aren't in jobIDs, e.g. of DeleteReports, are appended
+// in the order they were found.
+func mergeDeletes(jobIDs []string, outcomes []datacenterDeletes) []DeleteReport {
+	merged := map[string]*DeleteReport{}
+	order := []string{}
+	get := func(jobID string) *DeleteReport {
+		r, ok := merged[jobID]
+		if !ok {
+			r = &DeleteReport{JobID: jobID, Reason: ReasonNotFound}
+			merged[jobID] = r
+			order = append(order, jobID)
+		}
+		return r
+	}
+	for _, id := range jobIDs {
+		get(id)
+	}
+
+	var failed error
+	for _, dc := range outcomes {
+		if dc.err != nil {
+			failed = errors.Join(failed, fmt.Errorf("datacenter %s: %w", dc.server, dc.err))
+			continue
+		}
+		for _, e := range dc.entries {
+			r := get(e.JobID)
+			switch {
+			case r.Deleted:
+			case e.deleted():
+				*r = DeleteReport{JobID: e.JobID, Deleted: true, Datacenter: dc.server}
+			case e.Reason != ReasonNotFound:
+				r.Reason = e.Reason
+				if r.Reason == "" {
+					r.Reason = ReasonInternalError
+				}
+			}
+		}
+	}
+
+	reports := make([]DeleteReport, len(order))
+	for i, id := range order {
+		r := merged[id]
+		if !r.Deleted && failed != nil {
+			// the report may exist in a datacenter which failed
+			r.Reason, r.Err = ReasonInternalError, failed
+		}
+		reports[i] = *r
+	}
+	return reports
+}
+
+// allFailed returns the joined errors if no datacenter answered.
+func allFailed(outcomes []datacenterDeletes) error {
+	var errs []error
+	for _, dc := range outcomes {
+		if dc.err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("datacenter %s: %w", dc.server, dc.err))
+	}
+	return errors.Join(errs...)
+}
+
+// failedDatacenters returns the joined errors of the datacenters which didn't answer.
+func failedDatacenters(outcomes []datacenterDeletes) error {
+	var errs []error
+	for _, dc := range outcomes {
+		if dc.err != nil {
+			errs = append(errs, fmt.Errorf("datacenter %s: %w", dc.server, dc.err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,126 @@
+package fax
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteBulkReportsMergesDatacenters(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 4)
+	requested := []string{jobIDs[0], "FJ-UNKNOWN", jobIDs[1], jobIDs[2], jobIDs[3]}
+
+	res, err := client.DeleteBulkReports(requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(requested) {
+		t.Fatalf("expected one result per job id, got %v", res)
+	}
+	for i, r := range res {
+		if r.JobID != requested[i] {
+			t.Errorf("result %d is for %s instead of %s", i, r.JobID, requested[i])
+		}
+	}
+	if res[1].Deleted || res[1].Reason != ReasonNotFound || res[1].Err != nil {
+		t.Errorf("unknown job should be NOT_FOUND, got %+v", res[1])
+	}
+	for _, r := range append(res[:1:1], res[2:]...) {
+		if !r.Deleted || r.Reason != "" {
+			t.Errorf("job %s should be deleted, got %+v", r.JobID, r)
+		}
+		if dc := srv.JobDatacenter(r.JobID); dc != nil {
+			t.Errorf("report of %s still exists in %s", r.JobID, dc.Name)
+		}
+		if r.Datacenter == "" {
+			t.Errorf("job %s is missing the deleting datacenter", r.JobID)
+		}
+	}
+}
+
+func TestDeleteBulkReportsWithFailedDatacenter(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 2)
+	failed := srv.JobDatacenter(jobIDs[1])
+	failed.FailWith(http.StatusInternalServerError)
+
+	res, err := client.DeleteBulkReports(append(jobIDs, "FJ-UNKNOWN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res[0].Deleted || !strings.HasPrefix(res[0].Datacenter, srv.Datacenters[0].URL()) {
+		t.Errorf("job in the healthy datacenter should be deleted, got %+v", res[0])
+	}
+	for _, r := range res[1:] {
+		if r.Deleted || r.Reason != ReasonInternalError || !errors.Is(r.Err, ErrInternalServerError) {
+			t.Errorf("job %s may be in the failed datacenter, got %+v", r.JobID, r)
+		}
+	}
+
+	srv.Datacenters[0].FailWith(http.StatusInternalServerError)
+	if _, err := client.DeleteBulkReports(jobIDs); err == nil {
+		t.Error("expected an error if no datacenter answers")
+	}
+}
+
+func TestDeleteReportsCollectsAllDatacenters(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 4)
+	srv.Advance(time.Minute)
+
+	res, err := client.DeleteReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("expected the reports of both datacenters, got %v", res)
+	}
+	for _, id := range jobIDs {
+		if srv.JobDatacenter(id) != nil {
+			t.Errorf("report of %s wasn't deleted", id)
+		}
+	}
+
+	faxGeneratorCreator(client, 2)
+	srv.Advance(time.Minute)
+	srv.Datacenters[1].FailWith(http.StatusServiceUnavailable)
+	res, err = client.DeleteReports()
+	if !errors.Is(err, ErrServiceUnavailable) || len(res) != 1 {
+		t.Errorf("expected the report of the healthy datacenter and the error, got %v %v", res, err)
+	}
+}
+
+func TestDeleteReport(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 2)
+
+	res, err := client.DeleteReport(jobIDs[0])
+	if err != nil || !res.Deleted || res.JobID != jobIDs[0] {
+		t.Errorf("expected %s to be deleted, got %+v %v", jobIDs[0], res, err)
+	}
+	res, err = client.DeleteReport("FJ-UNKNOWN")
+	if err != nil || res.Deleted || res.Reason != ReasonNotFound {
+		t.Errorf("expected NOT_FOUND, got %+v %v", res, err)
+	}
+
+	srv.JobDatacenter(jobIDs[1]).FailWith(http.StatusInternalServerError)
+	res, err = client.DeleteReport(jobIDs[1])
+	if !errors.Is(err, ErrInternalServerError) || res.Deleted || res.Reason != ReasonInternalError {
+		t.Errorf("server errors shouldn't be swallowed, got %+v %v", res, err)
+	}
+}
+
+func TestMergeDeletesTreatsAbsentFlagAsDeleted(t *testing.T) {
+	no := false
+	outcomes := []datacenterDeletes{
+		{server: "dc1", entries: []deleteEntry{{JobID: "FJ1", Deleted: &no, Reason: ReasonNotFound}, {JobID: "FJ2"}}},
+		{server: "dc2", entries: []deleteEntry{{JobID: "FJ1"}, {JobID: "FJ2", Deleted: &no, Reason: ReasonNotFound}}},
+	}
+	res := mergeDeletes([]string{"FJ1", "FJ2"}, outcomes)
+	if !res[0].Deleted || res[0].Datacenter != "dc2" || !res[1].Deleted || res[1].Datacenter != "dc1" {
+		t.Errorf("unexpected merge %+v", res)
+	}
+}
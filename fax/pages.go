@@ -0,0 +1,208 @@
+package fax
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrUnknownPageCount is returned for documents whose pages can't be counted locally, e.g.
+// documents given by reference or in a format other than PDF and TIFF.
+var ErrUnknownPageCount = errors.New("page count of document can't be determined locally")
+
+// pdfPage matches the page objects of a PDF, but not the /Pages tree nodes.
+var pdfPage = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// countPages counts the pages of a PDF or TIFF document with inline data.
+func countPages(d Document) (int, error) {
+	if d.Data == "" {
+		return 0, ErrUnknownPageCount
+	}
+	var c pageCounter
+	if _, err := io.Copy(&c, base64.NewDecoder(base64.StdEncoding, strings.NewReader(d.Data))); err != nil {
+		return 0, err
+	}
+	return c.Count()
+}
+
+// pdfOverlap is the number of bytes kept between writes to find the page objects which were
+// split by a write.
+const pdfOverlap = 64
+
+// pageCounter counts the pages of a PDF or TIFF document which is written to it, so a streamed
+// document can be counted while it is sent. The image file directories of a TIFF, one per page,
+// must follow each other in the file, as they can't be read again once they were written.
+type pageCounter struct {
+	head  []byte // the first bytes until the format is known
+	write func(p []byte)
+	pages int
+	err   error
+
+	// tail is the end of the previous write of a PDF.
+	tail []byte
+
+	// the fields of a TIFF are read at increasing offsets, next reads the field at offset at
+	order binary.ByteOrder
+	pos   int64
+	at    int64
+	size  int
+	field []byte
+	next  func(field []byte)
+}
+
+// Write never fails, documents of other formats are ignored and counted as unknown.
+func (c *pageCounter) Write(p []byte) (int, error) {
+	if c.write == nil {
+		n := min(4-len(c.head), len(p))
+		c.head = append(c.head, p[:n]...)
+		if len(c.head) < 4 {
+			return len(p), nil
+		}
+		switch string(c.head) {
+		case "%PDF":
+			c.write = c.scanPDF
+		case "II*\x00":
+			c.order, c.write = binary.LittleEndian, c.walkTIFF
+			c.want(4, 4, c.readIFDOffset)
+		case "MM\x00*":
+			c.order, c.write = binary.BigEndian, c.walkTIFF
+			c.want(4, 4, c.readIFDOffset)
+		default:
+			c.err, c.write = ErrUnknownPageCount, func([]byte) {}
+		}
+		c.write(c.head)
+		c.write(p[n:])
+		return len(p), nil
+	}
+	c.write(p)
+	return len(p), nil
+}
+
+// Count returns the number of pages written so far. A PDF without page objects can't be counted,
+// they may be inside compressed object streams, neither can a TIFF whose last directory is missing.
+func (c *pageCounter) Count() (int, error) {
+	switch {
+	case c.err != nil:
+		return 0, c.err
+	case c.order == nil && c.pages == 0, c.order != nil && c.next != nil:
+		return 0, ErrUnknownPageCount
+	}
+	return c.pages, nil
+}
+
+func (c *pageCounter) scanPDF(p []byte) {
+	// page objects split by the previous write start in the tail and end in p
+	joined := append(c.tail, p[:min(len(p), pdfOverlap)]...)
+	for _, m := range pdfPage.FindAllIndex(joined, -1) {
+		if m[0] < len(c.tail) && m[1] > len(c.tail) {
+			c.pages++
+		}
+	}
+	c.pages += len(pdfPage.FindAllIndex(p, -1))
+	tail := append(c.tail, p[max(0, len(p)-pdfOverlap):]...)
+	c.tail = append(tail[:0], tail[max(0, len(tail)-pdfOverlap):]...)
+}
+
+// want makes walkTIFF read the size bytes at offset at and pass them to next.
+func (c *pageCounter) want(at int64, size int, next func(field []byte)) {
+	if at < c.pos {
+		c.err = ErrUnknownPageCount
+		return
+	}
+	c.at, c.size, c.field, c.next = at, size, c.field[:0], next
+}
+
+func (c *pageCounter) walkTIFF(p []byte) {
+	for len(p) > 0 && c.next != nil && c.err == nil {
+		if c.pos < c.at {
+			skip := int(min(int64(len(p)), c.at-c.pos))
+			c.pos, p = c.pos+int64(skip), p[skip:]
+			continue
+		}
+		n := min(c.size-len(c.field), len(p))
+		c.field = append(c.field, p[:n]...)
+		c.pos, p = c.pos+int64(n), p[n:]
+		if len(c.field) == c.size {
+			next := c.next
+			c.next = nil
+			next(c.field)
+		}
+	}
+	c.pos += int64(len(p))
+}
+
+// readIFDOffset follows the offset of the next image file directory, 0 ends the chain.
+func (c *pageCounter) readIFDOffset(field []byte) {
+	if offset := c.order.Uint32(field); offset != 0 {
+		c.want(int64(offset), 2, c.readIFD)
+	}
+}
+
+// readIFD skips the entries of an image file directory to the offset of the next one.
+func (c *pageCounter) readIFD(field []byte) {
+	entries := int64(c.order.Uint16(field))
+	c.want(c.pos+entries*12, 4, func(field []byte) {
+		c.pages++
+		c.readIFDOffset(field)
+	})
+}
+
+// TransmissionTimePerPage is a rough transmission time of a single page at the given resolution,
+// assuming a line speed of 14.4 kbit/s. The service default resolution is estimated like High.
+func TransmissionTimePerPage(r Resolution) time.Duration {
+	if r == Low {
+		return 30 * time.Second
+	}
+	return 60 * time.Second
+}
+
+// Estimation is the expected size of a fax job, see Estimate.
+type Estimation struct {
+	// Recipients is the number of recipients of the job.
+	Recipients int
+	// PagesPerRecipient is the number of pages sent to each recipient, including the cover page.
+	PagesPerRecipient int
+	// Pages is the number of page units of the job, counted over all recipients.
+	Pages int
+	// TransmissionTime is the summed transmission time of all pages to all recipients.
+	TransmissionTime time.Duration
+	// Uncounted lists the names of the documents whose pages can't be counted locally, e.g. office
+	// documents, references and streamed documents. They are left out of the estimation.
+	Uncounted []string
+}
+
+// Estimate counts the pages of the PDF and TIFF documents of the job locally, adds the cover page
+// if the job has a cover page template and multiplies them by the number of recipients. The
+// transmission time is estimated with TransmissionTimePerPage for the resolution of the job.
+func Estimate(job Job) Estimation {
+	var e Estimation
+	for _, d := range job.Documents {
+		p, err := countPages(d)
+		if err != nil {
+			e.Uncounted = append(e.Uncounted, d.Name)
+			continue
+		}
+		e.PagesPerRecipient += p
+	}
+	return e.withJob(job)
+}
+
+// withJob adds the cover page of the job to the counted pages of its documents and multiplies
+// them by the recipients of the job.
+func (e Estimation) withJob(job Job) Estimation {
+	e.Recipients = len(job.Recipients)
+	var resolution Resolution
+	if job.RenderingOptions != nil {
+		resolution = job.RenderingOptions.Resolution
+		if job.RenderingOptions.CoverpageTemplate != "" {
+			e.PagesPerRecipient++
+		}
+	}
+	e.Pages = e.PagesPerRecipient * e.Recipients
+	e.TransmissionTime = time.Duration(e.Pages) * TransmissionTimePerPage(resolution)
+	return e
+}
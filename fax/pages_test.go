@@ -0,0 +1,102 @@
+package fax
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// tiffWithPages builds a minimal little endian TIFF with the given number of empty directories.
+func tiffWithPages(pages int) []byte {
+	data := []byte("II*\x00")
+	data = binary.LittleEndian.AppendUint32(data, 8)
+	for i := 0; i < pages; i++ {
+		next := uint32(0)
+		if i < pages-1 {
+			next = uint32(len(data) + 6)
+		}
+		data = binary.LittleEndian.AppendUint16(data, 0)
+		data = binary.LittleEndian.AppendUint32(data, next)
+	}
+	return data
+}
+
+func TestCountPages(t *testing.T) {
+	pdf := "%PDF-1.4\n1 0 obj <</Type /Pages /Kids [2 0 R 3 0 R] /Count 2>> endobj\n" +
+		"2 0 obj <</Type /Page /Parent 1 0 R>> endobj\n3 0 obj <</Type/Page/Parent 1 0 R>> endobj\n%%EOF"
+	tests := []struct {
+		name  string
+		data  []byte
+		pages int
+	}{
+		{"pdf", []byte(pdf), 2},
+		{"tiff", tiffWithPages(3), 3},
+	}
+	for _, tt := range tests {
+		p, err := countPages(Document{Name: tt.name, Data: base64.StdEncoding.EncodeToString(tt.data)})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.name, err)
+		}
+		if p != tt.pages {
+			t.Errorf("%s: expected %d pages, got %d", tt.name, tt.pages, p)
+		}
+	}
+
+	if _, err := countPages(Document{Name: "ref.pdf", Reference: "https://example.com/ref.pdf"}); err != ErrUnknownPageCount {
+		t.Errorf("referenced documents can't be counted, got %v", err)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	pdf := "%PDF-1.4\n2 0 obj <</Type /Page>> endobj\n3 0 obj <</Type /Page>> endobj\n%%EOF"
+	job := Job{
+		Recipients: []Recipient{{Number: "+4989000000000"}, {Number: "+4989000000001"}, {Number: "+4989000000002"}},
+		Documents: []Document{
+			{Name: "a.pdf", Data: base64.StdEncoding.EncodeToString([]byte(pdf))},
+			{Name: "b.tif", Data: base64.StdEncoding.EncodeToString(tiffWithPages(4))},
+			{Name: "c.docx", Reference: "https://example.com/c.docx"},
+		},
+		RenderingOptions: &RenderingOptions{PaperFormat: A4, Resolution: Low, CoverpageTemplate: "coverpagedefault.ftl.html"},
+	}
+	e := Estimate(job)
+	if e.PagesPerRecipient != 7 || e.Pages != 21 || e.Recipients != 3 {
+		t.Errorf("unexpected page count %+v", e)
+	}
+	if e.TransmissionTime != 21*TransmissionTimePerPage(Low) {
+		t.Errorf("unexpected transmission time %s", e.TransmissionTime)
+	}
+	if len(e.Uncounted) != 1 || e.Uncounted[0] != "c.docx" {
+		t.Errorf("expected c.docx to be uncounted, got %v", e.Uncounted)
+	}
+
+	job.RenderingOptions = nil
+	if e := Estimate(job); e.Pages != 18 || e.TransmissionTime != 18*TransmissionTimePerPage(High) {
+		t.Errorf("unexpected estimation without rendering options %+v", e)
+	}
+}
+
+func TestPageCounterAcrossWrites(t *testing.T) {
+	pdf := "%PDF-1.4\n" + strings.Repeat("2 0 obj <</Type /Page>> endobj\n", 5) + "%%EOF"
+	for _, data := range [][]byte{[]byte(pdf), tiffWithPages(5)} {
+		for _, size := range []int{1, 3, 7, len(data)} {
+			var c pageCounter
+			for i := 0; i < len(data); i += size {
+				c.Write(data[i:min(i+size, len(data))])
+			}
+			if p, err := c.Count(); p != 5 || err != nil {
+				t.Errorf("%q in writes of %d bytes: expected 5 pages, got %d %v", data[:4], size, p, err)
+			}
+		}
+	}
+
+	// the first directory follows the second one, which was already written
+	tiff := tiffWithPages(2)
+	binary.LittleEndian.PutUint32(tiff[4:], 14)
+	binary.LittleEndian.PutUint32(tiff[16:], 8)
+	var c pageCounter
+	c.Write(tiff)
+	if _, err := c.Count(); err != ErrUnknownPageCount {
+		t.Errorf("expected a backward directory to be unknown, got %v", err)
+	}
+}
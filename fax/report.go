@@ -1,7 +1,11 @@
 package fax
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
+
+	"github.com/retarus/retarus-go/common"
 )
 
 type Report struct {
@@ -12,7 +16,22 @@ type Report struct {
 	// Pages (required)
 	Pages int `json:"pages"`
 	// Reference (optional)
-	Reference Reference `json:"reference,omitempty"`
+	Reference *Reference `json:"reference,omitempty"`
+}
+
+// finishedAt returns the time the last recipient of the report was processed, ok is false as long
+// as a recipient isn't Finished. Recipients which failed before dialing have no SentTS, if none of
+// the recipients has one, finished is zero.
+func (r Report) finishedAt() (finished time.Time, ok bool) {
+	for _, rs := range r.RecipientStatus {
+		if !rs.Finished() {
+			return time.Time{}, false
+		}
+		if rs.SentTS != nil && rs.SentTS.After(finished) {
+			finished = rs.SentTS.Time
+		}
+	}
+	return finished, len(r.RecipientStatus) > 0
 }
 
 type RecipientStatus struct {
@@ -21,12 +40,23 @@ type RecipientStatus struct {
 	// Status (required)
 	Status string `json:"status"`
 	// Reason (required) Explanation of the status.
-	Reason string    `json:"reason"`
-	SentTS time.Time `json:"sentTs"`
-	// DurationInSecs (required) Duration of the fax transmission until received by the fax recipient.
-	DurationInSecs int    `json:"durationInSecs"`
-	SentToNumber   string `json:"sentToNumber"`
-	RemoteCsid     string `json:"remoteCsid"`
+	Reason string `json:"reason"`
+	// SentTS (optional) is the time the transmission finished, it is missing while the
+	// recipient is pending.
+	SentTS *common.ISO8601Time `json:"sentTs,omitempty"`
+	// DurationInSecs (optional) Duration of the fax transmission until received by the fax recipient.
+	DurationInSecs int `json:"durationInSecs,omitempty"`
+	// SentToNumber (optional) is the number which was dialed, the primary or an alternative number.
+	SentToNumber string `json:"sentToNumber,omitempty"`
+	// RemoteCsid (optional) is the identification of the receiving fax machine.
+	RemoteCsid string `json:"remoteCsid,omitempty"`
+}
+
+// Finished reports whether the recipient has a final status. A failed recipient can be reported
+// without SentTS, so the Status decides and not the timestamp.
+func (s RecipientStatus) Finished() bool {
+	status := strings.TrimSpace(s.Status)
+	return status != "" && !strings.EqualFold(status, "PENDING")
 }
 
 type bulkReportRequest struct {
@@ -49,4 +79,20 @@ type DeleteReport struct {
 	// • NOT_FOUND: No report exists for the given job id.
 	// • INTERNAL_ERROR: Unspecified server-side error.
 	Reason string `json:"reason,omitempty"`
+	// Datacenter is the server which deleted the report, set by the client.
+	Datacenter string `json:"-"`
+	// Err is set by the client if the report wasn't deleted and a datacenter which might hold it
+	// failed, e.g. because it wasn't reachable. Reason is INTERNAL_ERROR then.
+	Err error `json:"-"`
+}
+
+// UnmarshalJSON decodes a DeleteReport of the API, where an absent deleted flag means the report
+// was deleted.
+func (r *DeleteReport) UnmarshalJSON(data []byte) error {
+	var entry deleteEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	*r = DeleteReport{JobID: entry.JobID, Deleted: entry.deleted(), Reason: entry.Reason}
+	return nil
 }
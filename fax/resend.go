@@ -0,0 +1,386 @@
+package fax
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamedResend is returned by Resender if a job has streamed documents, which can only be
+// sent once. Use inline data or a DocumentCache instead.
+var ErrStreamedResend = errors.New("streamed documents can't be resent")
+
+// DefaultRetryableReasons are the failure reasons which are resent by default, the recipient
+// line was busy or didn't answer.
+var DefaultRetryableReasons = []string{"BUSY", "NO_ANSWER"}
+
+// Default settings of a ResendPolicy.
+const (
+	DefaultResendDelay  = 5 * time.Minute
+	DefaultMaxResends   = 3
+	DefaultPollInterval = time.Minute
+)
+
+// ResendPolicy decides which failed recipients are resent, when and to which number.
+// The zero value resends busy and unanswered recipients up to DefaultMaxResends times to the same
+// number, DefaultResendDelay after their report shows the failure.
+type ResendPolicy struct {
+	// Retryable (optional) reports whether a failed recipient is resent, by default recipients
+	// whose Reason is one of DefaultRetryableReasons.
+	Retryable func(status RecipientStatus) bool
+	// Delay is the time between the failure and the resend, default DefaultResendDelay.
+	Delay time.Duration
+	// MaxResends is the maximum number of resends per recipient, default DefaultMaxResends.
+	MaxResends int
+	// NextNumber (optional) returns the number for the given resend attempt, starting at 1. By
+	// default the same number is dialed again, see UseAlternativeNumbers.
+	NextNumber func(recipient Recipient, attempt int) string
+}
+
+// UseAlternativeNumbers is a ResendPolicy.NextNumber which dials the AlternativeNumbers of the
+// recipient one after another and the primary number once they are used up.
+func UseAlternativeNumbers(recipient Recipient, attempt int) string {
+	if attempt <= len(recipient.AlternativeNumbers) {
+		return recipient.AlternativeNumbers[attempt-1]
+	}
+	return recipient.Number
+}
+
+func (p ResendPolicy) retryable(status RecipientStatus) bool {
+	if p.Retryable != nil {
+		return p.Retryable(status)
+	}
+	reason := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(status.Reason), " ", "_"))
+	for _, r := range DefaultRetryableReasons {
+		if reason == r {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ResendPolicy) delay() time.Duration {
+	if p.Delay <= 0 {
+		return DefaultResendDelay
+	}
+	return p.Delay
+}
+
+func (p ResendPolicy) maxResends() int {
+	if p.MaxResends <= 0 {
+		return DefaultMaxResends
+	}
+	return p.MaxResends
+}
+
+func (p ResendPolicy) nextNumber(recipient Recipient, attempt int) string {
+	if p.NextNumber == nil {
+		return recipient.Number
+	}
+	return p.NextNumber(recipient, attempt)
+}
+
+// Resend describes a recipient which was sent again.
+type Resend struct {
+	// OriginalJobID is the ID of the job the recipient was sent with first.
+	OriginalJobID string
+	// PreviousJobID is the ID of the failed job.
+	PreviousJobID string
+	// JobID is the ID of the new job.
+	JobID string
+	// Number is the number dialed by the new job.
+	Number string
+	// Attempt counts the resends of the recipient, starting at 1.
+	Attempt int
+}
+
+// Resender watches the reports of fax jobs and resends failed recipients according to a
+// ResendPolicy. A resent job is a copy of the original job with the failed recipient only, its
+// Reference is unchanged, so all attempts share the CustomerDefinedID of the original job.
+//
+//	resender := fax.NewResender(&client, fax.ResendPolicy{NextNumber: fax.UseAlternativeNumbers})
+//	jobID, err := resender.SendContext(ctx, job)
+//	go resender.Run(ctx)
+//
+// Note: To create a new instance of Resender, use the NewResender function.
+type Resender struct {
+	// PollInterval is the time between two Poll calls of Run, default DefaultPollInterval.
+	PollInterval time.Duration
+	// OnFinish (optional) is called by Poll with the chain of an original job once no resend can
+	// follow. The chain is forgotten afterwards, so Chain returns nil for it.
+	OnFinish func(chain []string)
+
+	api    API
+	policy ResendPolicy
+	now    func() time.Time
+
+	pollMu  sync.Mutex
+	mu      sync.Mutex
+	tracked map[string]*trackedJob
+	pending []*pendingResend
+	chains  map[string][]string
+}
+
+type trackedJob struct {
+	job      Job
+	original string
+	attempt  int
+	handled  map[string]bool
+	// recipients maps the dialed numbers to the recipients of the original job
+	recipients map[string]Recipient
+}
+
+type pendingResend struct {
+	due       time.Time
+	previous  *trackedJob
+	prevJobID string
+	recipient Recipient
+}
+
+// NewResender creates a Resender which sends and watches jobs with the given client.
+func NewResender(api API, policy ResendPolicy) *Resender {
+	return &Resender{
+		PollInterval: DefaultPollInterval,
+		api:          api,
+		policy:       policy,
+		now:          time.Now,
+		tracked:      map[string]*trackedJob{},
+		chains:       map[string][]string{},
+	}
+}
+
+// Send sends the job and watches its report.
+func (r *Resender) Send(job Job) (string, error) {
+	return r.SendContext(context.Background(), job)
+}
+
+// SendContext sends the job and watches its report. Jobs with streamed documents are rejected
+// with ErrStreamedResend before they are sent.
+func (r *Resender) SendContext(ctx context.Context, job Job) (string, error) {
+	if streamed(job) {
+		return "", ErrStreamedResend
+	}
+	jobID, err := r.api.SendContext(ctx, job)
+	if err != nil {
+		return "", err
+	}
+	r.Track(jobID, job)
+	return jobID, nil
+}
+
+// Track watches the report of a job which was sent before. Jobs with streamed documents can't be
+// resent and are rejected with ErrStreamedResend.
+func (r *Resender) Track(jobID string, job Job) error {
+	if streamed(job) {
+		return ErrStreamedResend
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recipients := map[string]Recipient{}
+	for _, rcpt := range job.Recipients {
+		recipients[rcpt.Number] = rcpt
+	}
+	r.tracked[jobID] = &trackedJob{job: job, original: jobID, handled: map[string]bool{}, recipients: recipients}
+	r.chains[jobID] = []string{jobID}
+	return nil
+}
+
+// streamed reports whether the job has documents with Content, which is used up by the first send.
+func streamed(job Job) bool {
+	for _, d := range job.Documents {
+		if d.Content != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Chain returns the IDs of the original job and of all jobs which resent its recipients, in the
+// order they were sent, as long as a resend can follow.
+func (r *Resender) Chain(originalJobID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.chains[originalJobID]...)
+}
+
+// Pending returns the number of watched jobs and scheduled resends.
+func (r *Resender) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.tracked) + len(r.pending)
+}
+
+// Run calls Poll every PollInterval until ctx is done. Errors of single polls are retried with
+// the next poll.
+func (r *Resender) Run(ctx context.Context) error {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the reports of all watched jobs, schedules the resend of failed recipients and
+// sends the resends which are due. It returns the resends sent by this poll and the errors of
+// fetching reports and sending jobs, which are retried with the next poll.
+func (r *Resender) Poll(ctx context.Context) ([]Resend, error) {
+	r.pollMu.Lock()
+	defer r.pollMu.Unlock()
+
+	var errs []error
+	r.mu.Lock()
+	jobs := make(map[string]*trackedJob, len(r.tracked))
+	for id, t := range r.tracked {
+		jobs[id] = t
+	}
+	r.mu.Unlock()
+	var finished [][]string
+	for jobID, t := range jobs {
+		report, err := r.api.GetReportContext(ctx, jobID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if chain := r.evaluate(jobID, t, report); chain != nil {
+			finished = append(finished, chain)
+		}
+	}
+
+	var resends []Resend
+	for _, p := range r.due() {
+		resend, err := r.resend(ctx, p)
+		if err != nil {
+			errs = append(errs, err)
+			r.mu.Lock()
+			r.pending = append(r.pending, p)
+			r.mu.Unlock()
+			continue
+		}
+		resends = append(resends, resend)
+	}
+	if r.OnFinish != nil {
+		for _, chain := range finished {
+			r.OnFinish(chain)
+		}
+	}
+	return resends, errors.Join(errs...)
+}
+
+// evaluate schedules the resend of the failed recipients of a report and stops watching the job
+// once every recipient is finished. It returns the chain of the original job if no resend can
+// follow anymore.
+func (r *Resender) evaluate(jobID string, t *trackedJob, report *Report) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range report.RecipientStatus {
+		if t.handled[status.Number] || !status.Finished() {
+			continue
+		}
+		t.handled[status.Number] = true
+		if strings.EqualFold(status.Status, "OK") || !r.policy.retryable(status) || t.attempt >= r.policy.maxResends() {
+			continue
+		}
+		recipient, ok := t.recipients[status.Number]
+		if !ok {
+			recipient = Recipient{Number: status.Number}
+		}
+		r.pending = append(r.pending, &pendingResend{
+			due:       r.now().Add(r.policy.delay()),
+			previous:  t,
+			prevJobID: jobID,
+			recipient: recipient,
+		})
+	}
+	if len(t.handled) >= len(t.job.Recipients) && len(report.RecipientStatus) > 0 {
+		delete(r.tracked, jobID)
+		return r.prune(t.original)
+	}
+	return nil
+}
+
+// prune forgets the chain of an original job and returns it, once none of its jobs is watched
+// and no resend is scheduled. r.mu must be held.
+func (r *Resender) prune(original string) []string {
+	for _, t := range r.tracked {
+		if t.original == original {
+			return nil
+		}
+	}
+	for _, p := range r.pending {
+		if p.previous.original == original {
+			return nil
+		}
+	}
+	chain := r.chains[original]
+	delete(r.chains, original)
+	return chain
+}
+
+// due removes the resends which are due from the schedule and returns them.
+func (r *Resender) due() []*pendingResend {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	var due []*pendingResend
+	waiting := r.pending[:0]
+	for _, p := range r.pending {
+		if now.Before(p.due) {
+			waiting = append(waiting, p)
+		} else {
+			due = append(due, p)
+		}
+	}
+	r.pending = waiting
+	return due
+}
+
+func (r *Resender) resend(ctx context.Context, p *pendingResend) (Resend, error) {
+	attempt := p.previous.attempt + 1
+	original := p.recipient
+	dialed := original
+	dialed.Number = r.policy.nextNumber(original, attempt)
+	// the dialed number must not be dialed again as alternative of itself
+	dialed.AlternativeNumbers = nil
+	for _, n := range original.AlternativeNumbers {
+		if n != dialed.Number {
+			dialed.AlternativeNumbers = append(dialed.AlternativeNumbers, n)
+		}
+	}
+
+	job := p.previous.job
+	job.Recipients = []Recipient{dialed}
+	jobID, err := r.api.SendContext(ctx, job)
+	if err != nil {
+		return Resend{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := &trackedJob{
+		job:        job,
+		original:   p.previous.original,
+		attempt:    attempt,
+		handled:    map[string]bool{},
+		recipients: map[string]Recipient{dialed.Number: original},
+	}
+	r.tracked[jobID] = t
+	r.chains[t.original] = append(r.chains[t.original], jobID)
+	return Resend{
+		OriginalJobID: t.original,
+		PreviousJobID: p.prevJobID,
+		JobID:         jobID,
+		Number:        dialed.Number,
+		Attempt:       attempt,
+	}, nil
+}
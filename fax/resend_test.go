@@ -0,0 +1,164 @@
+package fax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/retarustest"
+)
+
+func resenderProvider(t *testing.T, outcome func(number string) (string, string), policy ResendPolicy) (*Resender, *retarustest.Server) {
+	srv := retarustest.NewServer(retarustest.Options{FaxOutcome: outcome, DeliveryDelay: time.Minute})
+	t.Cleanup(srv.Close)
+	client := NewClient(Config{User: srv.User, Password: srv.Password, CustomerNumber: srv.CustomerNumber, Region: srv.FaxRegion()})
+	resender := NewResender(&client, policy)
+	resender.now = srv.Now
+	return resender, srv
+}
+
+func TestResendToAlternativeNumber(t *testing.T) {
+	outcome := func(number string) (string, string) {
+		if number == "+4989000000000" {
+			return "FAILED", "BUSY"
+		}
+		return "OK", "OK"
+	}
+	resender, srv := resenderProvider(t, outcome, ResendPolicy{Delay: 5 * time.Minute, NextNumber: UseAlternativeNumbers})
+	var finished [][]string
+	resender.OnFinish = func(chain []string) { finished = append(finished, chain) }
+	ctx := context.Background()
+
+	job := Job{
+		Reference:  &Reference{CustomerDefinedID: "notice-42"},
+		Recipients: []Recipient{{Number: "+4989000000000", AlternativeNumbers: []string{"+4989000000001"}}, {Number: "+4989000000002"}},
+		Documents:  []Document{{Name: "notice.txt", Data: "dGVzdGZheAo="}},
+	}
+	jobID, err := resender.SendContext(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Advance(time.Minute)
+	if resends, err := resender.Poll(ctx); err != nil || len(resends) != 0 {
+		t.Fatalf("the resend should wait for the delay, got %v %v", resends, err)
+	}
+	srv.Advance(5 * time.Minute)
+	resends, err := resender.Poll(ctx)
+	if err != nil || len(resends) != 1 {
+		t.Fatalf("expected one resend, got %v %v", resends, err)
+	}
+	resend := resends[0]
+	if resend.OriginalJobID != jobID || resend.PreviousJobID != jobID || resend.Number != "+4989000000001" || resend.Attempt != 1 {
+		t.Errorf("unexpected resend %+v", resend)
+	}
+
+	var sent Job
+	json.Unmarshal(srv.Job(resend.JobID), &sent)
+	if len(sent.Recipients) != 1 || sent.Recipients[0].Number != "+4989000000001" || sent.Reference.CustomerDefinedID != "notice-42" {
+		t.Errorf("unexpected resent job %+v", sent)
+	}
+	if alternatives := sent.Recipients[0].AlternativeNumbers; len(alternatives) != 0 {
+		t.Errorf("the dialed number shouldn't be an alternative of itself, got %v", alternatives)
+	}
+	if chain := resender.Chain(jobID); len(chain) != 2 || chain[1] != resend.JobID {
+		t.Errorf("unexpected chain %v", chain)
+	}
+
+	srv.Advance(time.Minute)
+	if resends, err := resender.Poll(ctx); err != nil || len(resends) != 0 {
+		t.Errorf("the alternative number was reached, got %v %v", resends, err)
+	}
+	if pending := resender.Pending(); pending != 0 {
+		t.Errorf("all jobs should be finished, %d are pending", pending)
+	}
+	if len(finished) != 1 || len(finished[0]) != 2 || finished[0][1] != resend.JobID || resender.Chain(jobID) != nil {
+		t.Errorf("expected the finished chain to be passed to OnFinish and forgotten, got %v", finished)
+	}
+}
+
+func TestResendGivesUp(t *testing.T) {
+	busy := func(string) (string, string) { return "FAILED", "BUSY" }
+	resender, srv := resenderProvider(t, busy, ResendPolicy{Delay: time.Minute, MaxResends: 2})
+	var finished [][]string
+	resender.OnFinish = func(chain []string) { finished = append(finished, chain) }
+	ctx := context.Background()
+
+	jobID, err := resender.SendContext(ctx, Job{Recipients: []Recipient{{Number: "+4989000000000"}}, Documents: []Document{{Name: "a.txt", Data: "dGVzdGZheAo="}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10 && resender.Pending() > 0; i++ {
+		srv.Advance(time.Minute)
+		if _, err := resender.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(finished) != 1 || len(finished[0]) != 3 || finished[0][0] != jobID {
+		t.Errorf("expected the original job and 2 resends, got %v", finished)
+	}
+	if pending := resender.Pending(); pending != 0 {
+		t.Errorf("resender should give up, %d jobs are pending", pending)
+	}
+}
+
+func TestResendSkipsPermanentFailures(t *testing.T) {
+	unobtainable := func(string) (string, string) { return "FAILED", "NUMBER_UNOBTAINABLE" }
+	resender, srv := resenderProvider(t, unobtainable, ResendPolicy{Delay: time.Minute})
+	var finished [][]string
+	resender.OnFinish = func(chain []string) { finished = append(finished, chain) }
+	ctx := context.Background()
+
+	jobID, _ := resender.SendContext(ctx, Job{Recipients: []Recipient{{Number: "+4989000000000"}}, Documents: []Document{{Name: "a.txt", Data: "dGVzdGZheAo="}}})
+	srv.Advance(time.Hour)
+	resender.Poll(ctx)
+	srv.Advance(time.Hour)
+	resender.Poll(ctx)
+	if len(finished) != 1 || len(finished[0]) != 1 || finished[0][0] != jobID || resender.Pending() != 0 {
+		t.Errorf("permanent failures shouldn't be resent, got %v", finished)
+	}
+}
+
+// unsentReportAPI reports every recipient as failed without sentTs.
+type unsentReportAPI struct {
+	API
+}
+
+func (a unsentReportAPI) GetReportContext(ctx context.Context, jobID string) (*Report, error) {
+	return &Report{JobID: jobID, RecipientStatus: []RecipientStatus{{Number: "+4989000000000", Status: "FAILED", Reason: "BUSY"}}}, nil
+}
+
+func TestResendFailureWithoutSentTS(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	resender := NewResender(unsentReportAPI{API: &client}, ResendPolicy{MaxResends: 1})
+	var finished [][]string
+	resender.OnFinish = func(chain []string) { finished = append(finished, chain) }
+	ctx := context.Background()
+
+	jobID, err := resender.SendContext(ctx, Job{Recipients: []Recipient{{Number: "+4989000000000"}}, Documents: []Document{{Name: "a.txt", Data: "dGVzdGZheAo="}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resender.Poll(ctx)
+	resender.now = func() time.Time { return time.Now().Add(time.Hour) }
+	resender.Poll(ctx)
+	resender.Poll(ctx)
+	if len(finished) != 1 || len(finished[0]) != 2 || finished[0][0] != jobID || resender.Pending() != 0 {
+		t.Errorf("expected the failed recipient to be resent once and the jobs to be finished, got %v and %d pending", finished, resender.Pending())
+	}
+}
+
+func TestResendRejectsStreamedDocuments(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	resender := NewResender(&client, ResendPolicy{})
+	job := Job{Recipients: []Recipient{{Number: "+4989000000000"}}, Documents: []Document{{Name: "a.txt", Content: strings.NewReader("once")}}}
+	if _, err := resender.Send(job); !errors.Is(err, ErrStreamedResend) {
+		t.Errorf("expected ErrStreamedResend, got %v", err)
+	}
+	if err := resender.Track("FJ1", job); !errors.Is(err, ErrStreamedResend) || resender.Pending() != 0 {
+		t.Errorf("expected ErrStreamedResend, got %v", err)
+	}
+}
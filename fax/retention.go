@@ -0,0 +1,403 @@
+package fax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default settings of a RetentionPolicy and RetentionManager. Retarus purges reports 30 days
+// after the job, so the default retention archives them well before.
+const (
+	DefaultRetention     = 25 * 24 * time.Hour
+	DefaultPurgeMargin   = 24 * time.Hour
+	DefaultPurgeInterval = time.Hour
+)
+
+// RetentionPolicy decides which reports a RetentionManager archives and deletes.
+type RetentionPolicy struct {
+	// MaxAge is the time after which a finished report is archived and deleted, default
+	// DefaultRetention.
+	MaxAge time.Duration
+	// Margin is the time before the StatusReportOptions.ReportPurgeTS of a job sent through the
+	// RetentionManager at which its report is archived and deleted, default DefaultPurgeMargin.
+	Margin time.Duration
+}
+
+func (p RetentionPolicy) maxAge() time.Duration {
+	if p.MaxAge <= 0 {
+		return DefaultRetention
+	}
+	return p.MaxAge
+}
+
+func (p RetentionPolicy) margin() time.Duration {
+	if p.Margin <= 0 {
+		return DefaultPurgeMargin
+	}
+	return p.Margin
+}
+
+// ArchiveSink stores reports before a RetentionManager deletes them. Archive must only return
+// once the reports are stored durably, reports are deleted after it returned without error.
+// Archiving the same report twice, e.g. after a failed delete, must be harmless.
+type ArchiveSink interface {
+	Archive(ctx context.Context, reports []Report) error
+}
+
+// DirSink is an ArchiveSink which writes every report to the file <JobID>.json in Dir.
+type DirSink struct {
+	Dir string
+}
+
+// Archive writes the reports to Dir, each to a temporary file which is renamed once it is synced.
+func (s DirSink) Archive(ctx context.Context, reports []Report) error {
+	for _, r := range reports {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(s.Dir, r.JobID+".*.tmp")
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(data)
+		if err == nil {
+			err = tmp.Sync()
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), filepath.Join(s.Dir, r.JobID+".json"))
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+// JSONLSink is an ArchiveSink which appends the reports to a file, one JSON report per line.
+// Note: To create a new instance of JSONLSink, use the OpenJSONLSink function.
+type JSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenJSONLSink opens or creates the file the reports are appended to.
+func OpenJSONLSink(path string) (*JSONLSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLSink{file: file}, nil
+}
+
+// Archive appends the reports and syncs the file.
+func (s *JSONLSink) Archive(ctx context.Context, reports []Report) error {
+	var lines []byte
+	for _, r := range reports {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(lines); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *JSONLSink) Close() error {
+	return s.file.Close()
+}
+
+// ObjectStore is an object storage, e.g. an S3-compatible bucket. PutObject stores data under key
+// and overwrites an existing object.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte) error
+}
+
+// ObjectSink is an ArchiveSink which puts every report as the object <Prefix><JobID>.json.
+type ObjectSink struct {
+	Store  ObjectStore
+	Prefix string
+}
+
+// Archive puts the reports one after another.
+func (s ObjectSink) Archive(ctx context.Context, reports []Report) error {
+	for _, r := range reports {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err := s.Store.PutObject(ctx, s.Prefix+r.JobID+".json", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetentionStats counts the reports of a RetentionManager.Purge run.
+type RetentionStats struct {
+	// Started is the time the run started.
+	Started time.Time
+	// Duration is the time the run took.
+	Duration time.Duration
+	// Scanned is the number of listed reports, Kept the number of them which aren't due yet.
+	Scanned, Kept int
+	// Archived is the number of reports stored in the ArchiveSink.
+	Archived int
+	// Deleted is the number of archived reports which were deleted, NotFound the number of them
+	// which were gone already and Failed the number which couldn't be deleted and are retried by
+	// the next run.
+	Deleted, NotFound, Failed int
+}
+
+// RetentionManager enforces a local retention policy on the fax reports of an account: reports
+// which are due are archived to an ArchiveSink and then deleted with DeleteBulkReports.
+//
+// The service doesn't return the StatusReportOptions of a job with its report, so the
+// ReportPurgeTS of a job is only known for jobs sent with SendContext or registered with Track.
+//
+//	sink, err := fax.OpenJSONLSink("reports.jsonl")
+//	retention := fax.NewRetentionManager(&client, sink, fax.RetentionPolicy{MaxAge: 7 * 24 * time.Hour})
+//	go retention.Run(ctx)
+//
+// Note: To create a new instance of RetentionManager, use the NewRetentionManager function.
+type RetentionManager struct {
+	// Interval is the time between two Purge calls of Run, default DefaultPurgeInterval.
+	Interval time.Duration
+	// OnPurge (optional) is called by Run with the outcome of every Purge.
+	OnPurge func(stats RetentionStats, err error)
+
+	client *Client
+	sink   ArchiveSink
+	policy RetentionPolicy
+	now    func() time.Time
+
+	purgeMu sync.Mutex
+	mu      sync.Mutex
+	purgeAt map[string]time.Time
+	// since is the time a job was sent or its report was first listed, the age of reports
+	// without SentTS is counted from it
+	since map[string]time.Time
+}
+
+// NewRetentionManager creates a RetentionManager which archives the reports of the client's
+// account to sink.
+func NewRetentionManager(client *Client, sink ArchiveSink, policy RetentionPolicy) *RetentionManager {
+	return &RetentionManager{
+		Interval: DefaultPurgeInterval,
+		client:   client,
+		sink:     sink,
+		policy:   policy,
+		now:      time.Now,
+		purgeAt:  map[string]time.Time{},
+		since:    map[string]time.Time{},
+	}
+}
+
+// Send sends the job and registers its ReportPurgeTS.
+func (m *RetentionManager) Send(job Job) (string, error) {
+	return m.SendContext(context.Background(), job)
+}
+
+// SendContext sends the job and registers its ReportPurgeTS.
+func (m *RetentionManager) SendContext(ctx context.Context, job Job) (string, error) {
+	jobID, err := m.client.SendContext(ctx, job)
+	if err != nil {
+		return "", err
+	}
+	m.Track(jobID, job)
+	return jobID, nil
+}
+
+// Track registers the ReportPurgeTS of a job which was sent before, jobs without it are archived
+// after the MaxAge of the policy only. If the recipients of the job fail before dialing, the
+// MaxAge is counted from the call of Track.
+func (m *RetentionManager) Track(jobID string, job Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.since[jobID] = m.now()
+	if job.StatusReportOptions == nil || job.StatusReportOptions.ReportPurgeTS == nil {
+		return
+	}
+	m.purgeAt[jobID] = job.StatusReportOptions.ReportPurgeTS.Time
+}
+
+// Run calls Purge every Interval until ctx is done. Reports which failed are retried with the
+// next run.
+func (m *RetentionManager) Run(ctx context.Context) error {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats, err := m.Purge(ctx)
+		if m.OnPurge != nil {
+			m.OnPurge(stats, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge archives and deletes the reports which are due. The service lists the oldest reports
+// only, so the reports are listed again as long as reports were deleted. Reports are only deleted
+// once the sink archived them, if the sink fails Purge stops and returns the error.
+func (m *RetentionManager) Purge(ctx context.Context) (stats RetentionStats, err error) {
+	m.purgeMu.Lock()
+	defer m.purgeMu.Unlock()
+
+	stats.Started = m.now()
+	defer func() { stats.Duration = m.now().Sub(stats.Started) }()
+	var errs []error
+	kept := map[string]bool{}
+	failed := map[string]bool{}
+	for {
+		var due []Report
+		err := m.client.EachReport(ctx, ReportQuery{}, func(r Report) error {
+			if kept[r.JobID] || failed[r.JobID] {
+				// listed again after the reports before it were deleted, the failed ones are
+				// retried by the next Purge
+				return nil
+			}
+			stats.Scanned++
+			if m.due(r) {
+				due = append(due, r)
+			} else {
+				kept[r.JobID] = true
+				stats.Kept++
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, errors.Join(append(errs, err)...)
+		}
+
+		deleted := 0
+		for start := 0; start < len(due); start += MaxBulkJobIDs {
+			batch := due[start:min(start+MaxBulkJobIDs, len(due))]
+			if err := m.sink.Archive(ctx, batch); err != nil {
+				return stats, errors.Join(append(errs, err)...)
+			}
+			stats.Archived += len(batch)
+			n, err := m.delete(ctx, batch, &stats, failed)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			deleted += n
+		}
+		if deleted == 0 {
+			return stats, errors.Join(errs...)
+		}
+	}
+}
+
+// due reports whether the report has to be archived, because it is older than the MaxAge or its
+// ReportPurgeTS is less than the Margin away. Finished reports without any SentTS are aged from
+// the time their job was tracked or their report was first listed.
+func (m *RetentionManager) due(r Report) bool {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if purge, ok := m.purgeAt[r.JobID]; ok && !now.Before(purge.Add(-m.policy.margin())) {
+		return true
+	}
+	finished, ok := r.finishedAt()
+	if !ok {
+		return false
+	}
+	if finished.IsZero() {
+		if _, ok := m.since[r.JobID]; !ok {
+			m.since[r.JobID] = now
+		}
+		finished = m.since[r.JobID]
+	}
+	return !now.Before(finished.Add(m.policy.maxAge()))
+}
+
+// delete deletes the archived reports and returns the number of deleted ones. The job IDs of the
+// reports which couldn't be deleted are added to failed.
+func (m *RetentionManager) delete(ctx context.Context, reports []Report, stats *RetentionStats, failedIDs map[string]bool) (int, error) {
+	jobIDs := make([]string, len(reports))
+	for i, r := range reports {
+		jobIDs[i] = r.JobID
+	}
+	results, err := m.client.DeleteBulkReportsContext(ctx, jobIDs)
+	if err != nil && results == nil {
+		stats.Failed += len(jobIDs)
+		for _, id := range jobIDs {
+			failedIDs[id] = true
+		}
+		return 0, err
+	}
+	deleted := 0
+	var failed error
+	reasons := map[string]int{}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range results {
+		switch {
+		case r.Deleted:
+			deleted++
+			stats.Deleted++
+			delete(m.purgeAt, r.JobID)
+			delete(m.since, r.JobID)
+		case r.Reason == ReasonNotFound:
+			stats.NotFound++
+			delete(m.purgeAt, r.JobID)
+			delete(m.since, r.JobID)
+		default:
+			stats.Failed++
+			failedIDs[r.JobID] = true
+			switch {
+			case r.Err == nil:
+				// the datacenter answered, but didn't delete the report
+				reasons[r.Reason]++
+			case failed == nil:
+				// the reports of a failed datacenter share its error
+				failed = r.Err
+			}
+		}
+	}
+	errs := []error{failed}
+	for _, reason := range sortedKeys(reasons) {
+		if reason == "" {
+			errs = append(errs, fmt.Errorf("%d reports weren't deleted", reasons[reason]))
+		} else {
+			errs = append(errs, fmt.Errorf("%d reports weren't deleted: %s", reasons[reason], reason))
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
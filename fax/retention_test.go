@@ -0,0 +1,201 @@
+package fax
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+type failingSink struct{}
+
+func (failingSink) Archive(ctx context.Context, reports []Report) error {
+	return errors.New("archive unavailable")
+}
+
+func TestRetentionManagerPurge(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	old := faxGeneratorCreator(client, 3)
+	srv.Advance(30 * 24 * time.Hour)
+	fresh := faxGeneratorCreator(client, 1)
+
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+	sink, err := OpenJSONLSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	manager := NewRetentionManager(&client, sink, RetentionPolicy{})
+	manager.now = srv.Now
+	purged, err := manager.Send(Job{
+		Recipients:          []Recipient{{Number: "+4989000000000"}},
+		Documents:           []Document{{Name: "a.txt", Data: "dGVzdGZheAo="}},
+		StatusReportOptions: &StatusReportOptions{ReportPurgeTS: common.NewISO8601Time(srv.Now().Add(12 * time.Hour))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Advance(time.Minute)
+
+	stats, err := manager.Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scanned != 5 || stats.Kept != 1 || stats.Archived != 4 || stats.Deleted != 4 || stats.Failed != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	for _, id := range append(old, purged) {
+		if srv.JobDatacenter(id) != nil {
+			t.Errorf("report of %s wasn't deleted", id)
+		}
+	}
+	if srv.JobDatacenter(fresh[0]) == nil {
+		t.Errorf("report of %s was deleted before it was due", fresh[0])
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	if lines != 4 {
+		t.Errorf("expected 4 archived reports, got %d", lines)
+	}
+}
+
+func TestRetentionManagerKeepsReportsIfArchiveFails(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 2)
+	srv.Advance(30 * 24 * time.Hour)
+
+	manager := NewRetentionManager(&client, failingSink{}, RetentionPolicy{})
+	manager.now = srv.Now
+	stats, err := manager.Purge(context.Background())
+	if err == nil || stats.Archived != 0 || stats.Deleted != 0 {
+		t.Errorf("expected the archive error, got %+v %v", stats, err)
+	}
+	for _, id := range jobIDs {
+		if srv.JobDatacenter(id) == nil {
+			t.Errorf("report of %s was deleted without being archived", id)
+		}
+	}
+}
+
+// failingDeleteTransport fails the bulk requests to one datacenter, listing works.
+type failingDeleteTransport struct {
+	host string
+}
+
+func (t failingDeleteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost && req.URL.Host == t.host {
+		return nil, errors.New("connection reset")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRetentionManagerCountsFailedDeletesOnce(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 4)
+	srv.Advance(30 * 24 * time.Hour)
+	broken, _ := url.Parse(srv.JobDatacenter(jobIDs[0]).URL())
+	client.Transporter.HTTPClient.Transport = failingDeleteTransport{host: broken.Host}
+
+	manager := NewRetentionManager(&client, DirSink{Dir: t.TempDir()}, RetentionPolicy{})
+	manager.now = srv.Now
+	stats, err := manager.Purge(context.Background())
+	if err == nil {
+		t.Error("expected the error of the failed datacenter")
+	}
+	if stats.Scanned != 4 || stats.Archived != 4 || stats.Deleted != 2 || stats.Failed != 2 {
+		t.Errorf("expected every report to be archived and counted once, got %+v", stats)
+	}
+}
+
+// refusingDeleteTransport lets one datacenter answer bulk deletes with deleted false.
+type refusingDeleteTransport struct {
+	host string
+}
+
+func (t refusingDeleteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.URL.Host != t.host {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	var bulk bulkReportRequest
+	if err := json.NewDecoder(req.Body).Decode(&bulk); err != nil {
+		return nil, err
+	}
+	reports := []map[string]any{}
+	for _, id := range bulk.JobIDs {
+		reports = append(reports, map[string]any{"jobId": id, "deleted": false, "reason": ReasonInternalError})
+	}
+	body, _ := json.Marshal(map[string]any{"reports": reports})
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body)), Request: req}, nil
+}
+
+func TestRetentionManagerReportsRefusedDeletes(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	jobIDs := faxGeneratorCreator(client, 4)
+	srv.Advance(30 * 24 * time.Hour)
+	refusing, _ := url.Parse(srv.JobDatacenter(jobIDs[0]).URL())
+	client.Transporter.HTTPClient.Transport = refusingDeleteTransport{host: refusing.Host}
+
+	manager := NewRetentionManager(&client, DirSink{Dir: t.TempDir()}, RetentionPolicy{})
+	manager.now = srv.Now
+	stats, err := manager.Purge(context.Background())
+	if stats.Failed != 2 || err == nil || !strings.Contains(err.Error(), "2 reports weren't deleted: "+ReasonInternalError) {
+		t.Errorf("expected the refused deletes to be returned as error, got %+v, %v", stats, err)
+	}
+}
+
+func TestRetentionManagerAgesReportsWithoutSentTS(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	manager := NewRetentionManager(&client, DirSink{Dir: t.TempDir()}, RetentionPolicy{})
+	manager.now = srv.Now
+	// the recipient failed before dialing, so its report has no sentTs
+	var report Report
+	if err := json.Unmarshal([]byte(`{"jobId":"FJ1","pages":1,"recipientStatus":[{"number":"+4989000000000","status":"FAILED","reason":"INVALID_NUMBER"}]}`), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if manager.due(report) {
+		t.Error("expected the report to be kept until it is MaxAge old")
+	}
+	srv.Advance(DefaultRetention)
+	if !manager.due(report) {
+		t.Error("expected the report to be due MaxAge after it was first listed")
+	}
+	if !(ReportQuery{From: srv.Now(), Status: "FAILED"}).match(report) {
+		t.Error("expected the report without time to match the query")
+	}
+	report.RecipientStatus[0].Status = "PENDING"
+	if (ReportQuery{From: srv.Now()}).match(report) {
+		t.Error("expected a pending report not to match")
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	reports := []Report{{JobID: "FJ1"}, {JobID: "FJ2"}}
+	if err := (DirSink{Dir: dir}).Archive(context.Background(), reports); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 || filepath.Base(files[0]) != "FJ1.json" || filepath.Base(files[1]) != "FJ2.json" {
+		t.Errorf("expected one file per report, got %v", files)
+	}
+}
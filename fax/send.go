@@ -0,0 +1,113 @@
+package fax
+
+import (
+	"context"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+// SendOptions configure Client.SendWithOptions.
+type SendOptions struct {
+	// RejectSuppressed (optional) fails the send with common.ErrSuppressed if any number of the job
+	// is suppressed, instead of sending the job without it.
+	RejectSuppressed bool
+	// IdempotencyKey (optional) identifies the send, e.g. by the ID of a queued message, so a
+	// retried send with the same key returns the job ID of the first one. It requires
+	// Client.Idempotency.
+	IdempotencyKey string
+}
+
+// SendResult is the result of Client.SendWithOptions.
+type SendResult struct {
+	// JobID is the ID of the sent job, empty if it wasn't sent.
+	JobID string
+	// Replayed is true if the job was already sent with the same IdempotencyKey and wasn't sent
+	// again, Suppressed is empty then.
+	Replayed bool
+	// Suppressed are the numbers which were removed from the job, in the order of the job.
+	Suppressed []SuppressedRecipient
+}
+
+// SuppressedRecipient is a recipient of a job with a suppressed number. If Number is the number of
+// the recipient, the whole recipient was removed, otherwise only the alternative number.
+type SuppressedRecipient struct {
+	Recipient Recipient
+	Number    string
+	Entry     common.SuppressionEntry
+}
+
+// suppress returns the job without the suppressed recipients and alternative numbers. The
+// recipients of the given job aren't modified.
+func suppress(ctx context.Context, s common.Suppression, job Job) (Job, []SuppressedRecipient, error) {
+	if s == nil {
+		return job, nil, nil
+	}
+	type lookup struct {
+		entry common.SuppressionEntry
+		ok    bool
+	}
+	seen := map[string]lookup{}
+	check := func(number string) (lookup, error) {
+		if l, ok := seen[number]; ok {
+			return l, nil
+		}
+		entry, ok, err := s.Lookup(ctx, number)
+		if err != nil {
+			return lookup{}, err
+		}
+		seen[number] = lookup{entry, ok}
+		return seen[number], nil
+	}
+
+	var suppressed []SuppressedRecipient
+	recipients := make([]Recipient, 0, len(job.Recipients))
+	for _, r := range job.Recipients {
+		l, err := check(r.Number)
+		if err != nil {
+			return job, nil, err
+		}
+		if l.ok {
+			suppressed = append(suppressed, SuppressedRecipient{Recipient: r, Number: r.Number, Entry: l.entry})
+			continue
+		}
+		var alternatives []string
+		for _, alt := range r.AlternativeNumbers {
+			l, err := check(alt)
+			if err != nil {
+				return job, nil, err
+			}
+			if l.ok {
+				suppressed = append(suppressed, SuppressedRecipient{Recipient: r, Number: alt, Entry: l.entry})
+				continue
+			}
+			alternatives = append(alternatives, alt)
+		}
+		if len(alternatives) != len(r.AlternativeNumbers) {
+			r.AlternativeNumbers = alternatives
+		}
+		recipients = append(recipients, r)
+	}
+	if len(suppressed) == 0 {
+		return job, nil, nil
+	}
+	job.Recipients = recipients
+	return job, suppressed, nil
+}
+
+// maxCustomerDefinedID is the maximum length of Reference.CustomerDefinedID.
+const maxCustomerDefinedID = 256
+
+// withCustomerDefinedID returns the job with key as Reference.CustomerDefinedID if it has none,
+// without modifying the reference of the given job.
+func withCustomerDefinedID(job Job, key string) Job {
+	if len(key) > maxCustomerDefinedID || (job.Reference != nil && job.Reference.CustomerDefinedID != "") {
+		return job
+	}
+	reference := Reference{}
+	if job.Reference != nil {
+		reference = *job.Reference
+	}
+	reference.CustomerDefinedID = key
+	job.Reference = &reference
+	return job
+}
@@ -0,0 +1,134 @@
+package fax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+func TestSendRemovesSuppressedNumbers(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	client.Suppression = common.NewMemorySuppression(
+		common.SuppressionEntry{Number: "+4989000001", Reason: common.DoNotSend},
+		common.SuppressionEntry{Number: "+4989000012", Reason: common.HardBounce},
+	)
+	job := Job{
+		Recipients: []Recipient{
+			NewRecipient("+49 89 000001", nil, nil),
+			NewRecipient("+4989000002", []string{"+4989000012", "+4989000022"}, nil),
+		},
+		Documents: []Document{{Name: "fax.txt", Data: "aGVsbG8="}},
+	}
+
+	result, err := client.SendWithOptions(context.Background(), job, SendOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Suppressed) != 2 || result.Suppressed[0].Number != "+49 89 000001" || result.Suppressed[1].Number != "+4989000012" {
+		t.Fatalf("unexpected suppressed numbers %+v", result.Suppressed)
+	}
+
+	var sent Job
+	if err := json.Unmarshal(srv.Job(result.JobID), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Recipients) != 1 || sent.Recipients[0].Number != "+4989000002" || len(sent.Recipients[0].AlternativeNumbers) != 1 || sent.Recipients[0].AlternativeNumbers[0] != "+4989000022" {
+		t.Errorf("unexpected sent recipients %+v", sent.Recipients)
+	}
+	if len(job.Recipients[1].AlternativeNumbers) != 2 {
+		t.Error("the job of the caller must not be modified")
+	}
+
+	result, err = client.SendWithOptions(context.Background(), job, SendOptions{RejectSuppressed: true})
+	if !errors.Is(err, common.ErrSuppressed) || result.JobID != "" {
+		t.Errorf("expected ErrSuppressed without a job, got %q, %v", result.JobID, err)
+	}
+	job.Recipients = job.Recipients[:1]
+	if _, err := client.Send(job); !errors.Is(err, common.ErrAllSuppressed) {
+		t.Errorf("expected ErrAllSuppressed, got %v", err)
+	}
+}
+
+type failingSuppression struct{}
+
+func (failingSuppression) Lookup(ctx context.Context, number string) (common.SuppressionEntry, bool, error) {
+	return common.SuppressionEntry{}, false, errors.New("database unavailable")
+}
+
+func TestSendFailsIfSuppressionFails(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	client.Suppression = failingSuppression{}
+	if _, err := client.Send(Job{Recipients: []Recipient{NewRecipient("+4989000001", nil, nil)}}); err == nil {
+		t.Fatal("expected the send to fail if the suppression list can't be checked")
+	}
+}
+
+func TestSendWithIdempotencyKey(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	client.Idempotency = common.NewMemoryIdempotencyStore()
+	ctx := context.Background()
+	job := Job{
+		Recipients: []Recipient{NewRecipient("+4989000001", nil, nil)},
+		Documents:  []Document{{Name: "fax.txt", Data: "aGVsbG8="}},
+		Reference:  &Reference{BillingCode: "sales"},
+	}
+
+	first, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-42"})
+	if err != nil || !retry.Replayed || retry.JobID != first.JobID {
+		t.Fatalf("expected the retry to return job %s, got %+v, %v", first.JobID, retry, err)
+	}
+
+	var sent Job
+	if err := json.Unmarshal(srv.Job(first.JobID), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Reference == nil || sent.Reference.CustomerDefinedID != "order-42" || sent.Reference.BillingCode != "sales" {
+		t.Errorf("expected the key as customer defined ID, got %+v", sent.Reference)
+	}
+	if job.Reference.CustomerDefinedID != "" {
+		t.Error("the job of the caller must not be modified")
+	}
+
+	job.Reference.CustomerDefinedID = "invoice-7"
+	result, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-43"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(srv.Job(result.JobID), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Reference.CustomerDefinedID != "invoice-7" {
+		t.Errorf("expected the customer defined ID of the job to be kept, got %q", sent.Reference.CustomerDefinedID)
+	}
+}
+
+func TestSendKeepsIdempotencyKeyOnUnknownOutcome(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	client.Idempotency = common.NewMemoryIdempotencyStore()
+	ctx := context.Background()
+	job := Job{
+		Recipients: []Recipient{NewRecipient("+4989000001", nil, nil)},
+		Documents:  []Document{{Name: "fax.txt", Data: "aGVsbG8="}},
+	}
+
+	srv.HA.FailWith(http.StatusBadRequest)
+	if _, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-42"}); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+	srv.HA.FailWith(http.StatusServiceUnavailable)
+	if _, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-42"}); !errors.Is(err, ErrServiceUnavailable) {
+		t.Fatalf("expected the rejected send to release the key, got %v", err)
+	}
+	srv.HA.FailWith(0)
+	if _, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-42"}); !errors.Is(err, common.ErrIdempotencyKeyInUse) {
+		t.Fatalf("expected the key to stay claimed after the unknown outcome, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package fax
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+var (
+	// ErrDocumentTooLarge is returned by Send if a document exceeds Config.MaxDocumentSize.
+	ErrDocumentTooLarge = errors.New("document exceeds the maximum document size")
+	// ErrJobTooLarge is returned by Send if all documents of a job exceed Config.MaxJobSize.
+	ErrJobTooLarge = errors.New("job exceeds the maximum job size")
+)
+
+// NewDocumentFromStream returns a Document whose content is read from r while the job is sent,
+// see Document.Content. The file type is detected from the first bytes of r, for zip based Office
+// documents the extension of name decides.
+func NewDocumentFromStream(name string, r io.Reader) (Document, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return Document{}, err
+	}
+	if len(head) == 0 {
+		return Document{}, ErrEmptyDocument
+	}
+	ext, charset := DetectFileType(trimIncompleteRune(head))
+	if ext == "" && bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		switch t := typeOfExtension(extensionOf(name)); t {
+		case "docx", "xlsx", "pptx", "odt", "ods", "odp":
+			ext = t
+		}
+	}
+	if ext == "" {
+		return Document{}, fmt.Errorf("%w: %s", ErrUnsupportedDocument, name)
+	}
+	return Document{Name: documentName(name, ext), Charset: charset, Content: br}, nil
+}
+
+// trimIncompleteRune removes a multi byte character which was cut at the end of head.
+func trimIncompleteRune(head []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+		if utf8.RuneStart(head[len(head)-i]) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				return head[:len(head)-i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+func extensionOf(name string) string {
+	for i := len(name) - 1; i >= 0 && name[i] != '/'; i-- {
+		if name[i] == '.' {
+			return name[i:]
+		}
+	}
+	return ""
+}
+
+// sizeLimits returns the configured size limits or their defaults.
+func (c Config) sizeLimits() (maxDocument, maxJob int64) {
+	maxDocument, maxJob = c.MaxDocumentSize, c.MaxJobSize
+	if maxDocument <= 0 {
+		maxDocument = DefaultMaxDocumentSize
+	}
+	if maxJob <= 0 {
+		maxJob = DefaultMaxJobSize
+	}
+	return maxDocument, maxJob
+}
+
+// checkInlineSizes checks the size of the documents with inline data, so the request isn't even
+// started if they are too large.
+func checkInlineSizes(job Job, maxDocument, maxJob int64) error {
+	var total int64
+	for _, d := range job.Documents {
+		if d.Content != nil || d.Reference != "" {
+			continue
+		}
+		size := int64(base64.StdEncoding.DecodedLen(len(d.Data)))
+		if size > maxDocument {
+			return fmt.Errorf("%w: %s", ErrDocumentTooLarge, d.Name)
+		}
+		if total += size; total > maxJob {
+			return ErrJobTooLarge
+		}
+	}
+	return nil
+}
+
+// encodeJob writes the JSON encoding of the job to w. The documents are written one after
+// another, the Content of a document is base64 encoded while it is read. If pages isn't nil, the
+// pages of the documents are counted on the way and stored in it, or -1 if a document can't be
+// counted locally, see Estimate.
+func encodeJob(w io.Writer, job Job, maxDocument, maxJob int64, pages *int) error {
+	count := func(p int, err error) {
+		if pages == nil || *pages < 0 {
+			return
+		}
+		if *pages += p; err != nil {
+			*pages = -1
+		}
+	}
+	if pages != nil {
+		*pages = 0
+	}
+	documents := job.Documents
+	job.Documents = nil
+	head, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		_, err := w.Write(head)
+		return err
+	}
+
+	// the job always has the recipients field, so the documents are appended after it
+	bw := bufio.NewWriter(w)
+	bw.Write(head[:len(head)-1])
+	bw.WriteString(`,"documents":[`)
+	var total int64
+	for i, d := range documents {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		content, data := d.Content, d.Data
+		d.Content, d.Data = nil, ""
+		meta, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		if content == nil && data == "" {
+			count(0, ErrUnknownPageCount)
+			bw.Write(meta)
+			continue
+		}
+		bw.Write(meta[:len(meta)-1])
+		bw.WriteString(`,"data":"`)
+		if content == nil {
+			// inline data is already base64 and was checked by checkInlineSizes
+			total += int64(base64.StdEncoding.DecodedLen(len(data)))
+			if pages != nil {
+				count(countPages(Document{Data: data}))
+			}
+			writeJSONString(bw, data)
+			bw.WriteString(`"}`)
+			continue
+		}
+		limit := min(maxDocument, maxJob-total)
+		var counter pageCounter
+		if pages != nil {
+			content = io.TeeReader(content, &counter)
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, bw)
+		n, err := io.Copy(enc, io.LimitReader(content, limit+1))
+		if err != nil {
+			return fmt.Errorf("reading document %s: %w", d.Name, err)
+		}
+		switch {
+		case n > maxDocument:
+			return fmt.Errorf("%w: %s", ErrDocumentTooLarge, d.Name)
+		case total+n > maxJob:
+			return ErrJobTooLarge
+		}
+		total += n
+		count(counter.Count())
+		enc.Close()
+		bw.WriteString(`"}`)
+	}
+	bw.WriteString("]}")
+	return bw.Flush()
+}
+
+// writeJSONString writes the content of a JSON string without quotes, base64 data is written as is.
+func writeJSONString(w *bufio.Writer, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c == '"' || c == '\\' || c >= utf8.RuneSelf {
+			quoted, _ := json.Marshal(s)
+			w.Write(quoted[1 : len(quoted)-1])
+			return
+		}
+	}
+	w.WriteString(s)
+}
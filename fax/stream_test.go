@@ -0,0 +1,150 @@
+package fax
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+func TestEncodeJobMatchesMarshal(t *testing.T) {
+	job := Job{
+		Reference:  &Reference{CustomerDefinedID: "order \"42\""},
+		Recipients: []Recipient{{Number: "+4989000000000"}},
+		Documents: []Document{
+			{Name: "a.txt", Charset: UTF_8, Data: "dGVzdGZheAo="},
+			{Name: "b.pdf", Reference: "https://example.com/b.pdf"},
+		},
+	}
+	expected, _ := json.Marshal(job)
+
+	var buf bytes.Buffer
+	if err := encodeJob(&buf, job, DefaultMaxDocumentSize, DefaultMaxJobSize, nil); err != nil {
+		t.Fatal(err)
+	}
+	var got, want any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %s: %s", buf.Bytes(), err)
+	}
+	json.Unmarshal(expected, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %s, got %s", expected, buf.Bytes())
+	}
+}
+
+func TestSendStreamedDocument(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	content := strings.Repeat("streamed fax line\n", 10000)
+	document, err := NewDocumentFromStream("letter.txt", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.Name != "letter.txt" || document.Charset != UTF_8 {
+		t.Errorf("unexpected document %+v", document)
+	}
+
+	jobID, err := client.Send(Job{Recipients: []Recipient{{Number: "+4989000000000"}}, Documents: []Document{document}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent Job
+	if err := json.Unmarshal(srv.Job(jobID), &sent); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(sent.Documents[0].Data)
+	if string(data) != content {
+		t.Errorf("server received %d bytes instead of %d", len(data), len(content))
+	}
+}
+
+func TestSendSizeLimits(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	client.Config.MaxDocumentSize = 100
+	client.Config.MaxJobSize = 150
+	recipients := []Recipient{{Number: "+4989000000000"}}
+	text := func(n int) Document {
+		return Document{Name: "a.txt", Content: strings.NewReader(strings.Repeat("x", n))}
+	}
+
+	tests := []struct {
+		name      string
+		documents []Document
+		err       error
+	}{
+		{"streamed document", []Document{text(101)}, ErrDocumentTooLarge},
+		{"inline document", []Document{{Name: "a.txt", Data: base64.StdEncoding.EncodeToString(make([]byte, 120))}}, ErrDocumentTooLarge},
+		{"streamed job", []Document{text(100), text(60)}, ErrJobTooLarge},
+		{"within limits", []Document{text(100), text(50)}, nil},
+	}
+	for _, tt := range tests {
+		_, err := client.Send(Job{Recipients: recipients, Documents: tt.documents})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+	if requests := srv.HA.Requests(); requests > 3 {
+		t.Errorf("inline documents should be rejected before sending, got %d requests", requests)
+	}
+}
+
+func TestSendStreamedDocumentTimeout(t *testing.T) {
+	client, srv := faxClientProvider(t)
+	client.Idempotency = common.NewMemoryIdempotencyStore()
+	srv.HA.Hang(true)
+	document := Document{Name: "a.txt", Content: strings.NewReader(strings.Repeat("x", 10<<20))}
+	job := Job{Recipients: []Recipient{{Number: "+4989000000000"}}, Documents: []Document{document}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "order-42"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	// the job may have been created by the interrupted upload, so the key stays claimed
+	if _, err := client.SendWithOptions(context.Background(), job, SendOptions{IdempotencyKey: "order-42"}); !errors.Is(err, common.ErrIdempotencyKeyInUse) {
+		t.Errorf("expected ErrIdempotencyKeyInUse, got %v", err)
+	}
+}
+
+// pageMetrics records the fax pages passed to common.Metrics.
+type pageMetrics struct {
+	mu    sync.Mutex
+	pages []int
+}
+
+func (m *pageMetrics) ObserveRequest(common.RequestObservation) {}
+func (m *pageMetrics) AddSMSParts(int)                          {}
+func (m *pageMetrics) ObservePollingLag(string, time.Duration)  {}
+func (m *pageMetrics) AddFaxPages(pages int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pages = append(m.pages, pages)
+}
+
+func TestSendCountsStreamedPages(t *testing.T) {
+	client, _ := faxClientProvider(t)
+	metrics := &pageMetrics{}
+	client.Transporter.Metrics = metrics
+	pdf := "%PDF-1.4\n" + strings.Repeat("2 0 obj <</Type /Page>> endobj\n", 3) + "%%EOF"
+	recipients := []Recipient{{Number: "+4989000000000"}, {Number: "+4989000000001"}}
+
+	streamed := Document{Name: "a.pdf", Content: strings.NewReader(pdf)}
+	inline := Document{Name: "b.tif", Data: base64.StdEncoding.EncodeToString(tiffWithPages(2))}
+	if _, err := client.Send(Job{Recipients: recipients, Documents: []Document{streamed, inline}}); err != nil {
+		t.Fatal(err)
+	}
+	referenced := Document{Name: "c.docx", Reference: "https://example.com/c.docx"}
+	if _, err := client.Send(Job{Recipients: recipients, Documents: []Document{inline, referenced}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metrics.pages, []int{10}) {
+		t.Errorf("expected 10 pages of the first job only, got %v", metrics.pages)
+	}
+}
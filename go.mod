@@ -1,3 +1,8 @@
 module github.com/retarus/retarus-go
 
-go 1.19
+go 1.21
+
+require (
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+// ReadCSV reads a recipient list from CSV. A UTF-8 byte order mark is skipped, rows may have
+// different numbers of fields. Malformed rows are rejected, the error is only set if the input
+// can't be read at all or a mapped column doesn't exist.
+func ReadCSV(r io.Reader, opts Options) (*List, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	if opts.Comma == 0 {
+		opts.Comma = detectComma(br)
+	}
+	reader := csv.NewReader(br)
+	reader.Comma = opts.Comma
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	b := newBuilder(opts)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			b.list.Rejected = append(b.list.Rejected, Rejection{Line: parseErr.StartLine, Fields: fields, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if err := b.add(line, fields); err != nil {
+			return nil, err
+		}
+	}
+	return b.list, nil
+}
+
+// detectComma returns the most frequent of ',', ';' and tab in the first line, ',' if there is none.
+func detectComma(br *bufio.Reader) rune {
+	first, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	comma, most := ',', 0
+	for _, c := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(first, []byte(string(c))); n > most {
+			comma, most = c, n
+		}
+	}
+	return comma
+}
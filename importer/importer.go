@@ -0,0 +1,369 @@
+// Package importer reads recipient lists from CSV and XLSX files for the sms and fax packages.
+//
+// Every row yields a normalized number, an optional customer reference and alternative numbers,
+// and the remaining columns as variables for templating. Rows which can't be used are collected
+// with their line number instead of failing the whole import:
+//
+//	list, err := importer.ReadFile("contacts.csv", importer.Options{DefaultCountryCode: "49"})
+//	for _, r := range list.Rejected {
+//		log.Printf("line %d: %s", r.Line, r.Reason)
+//	}
+//	job, err := tmpl.Job(list.TemplateRecipients(), &sms.Options{})
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/retarus/retarus-go/fax"
+	"github.com/retarus/retarus-go/sms"
+)
+
+// ErrUnsupportedFile is returned by ReadFile for files which are neither CSV nor XLSX.
+var ErrUnsupportedFile = errors.New("unsupported recipient list, expected .csv, .tsv, .txt or .xlsx")
+
+// HeaderMode tells whether the first row of a list is a header.
+type HeaderMode int
+
+const (
+	// DetectHeader treats the first row as header if none of its cells is a phone number.
+	DetectHeader HeaderMode = iota
+	// WithHeader always treats the first row as header.
+	WithHeader
+	// WithoutHeader treats the first row as data.
+	WithoutHeader
+)
+
+// numberHeaders are the header names which are used as number column if Options.Number is empty.
+var numberHeaders = []string{"number", "phone", "mobile", "dst", "msisdn", "fax", "faxnumber", "phonenumber", "mobilenumber"}
+
+// Options configure how a list is read. Columns are given by their header name, case-insensitive,
+// or by their 1-based position like "3" if the list has no header.
+type Options struct {
+	// Comma (optional) is the CSV delimiter, by default the most frequent of ',', ';' and tab in
+	// the first line.
+	Comma rune
+	// Header (optional) tells whether the first row is a header, default DetectHeader.
+	Header HeaderMode
+	// Number (optional) is the column of the phone or fax number, by default the first column with
+	// a header like "number", "phone" or "mobile", or without header the first column with a
+	// number in the first row.
+	Number string
+	// CustomerRef (optional) is the column of the customer reference.
+	CustomerRef string
+	// AlternativeNumbers (optional) is the column of alternative fax numbers, separated by ';' or '|'.
+	AlternativeNumbers string
+	// DefaultCountryCode (optional) replaces the leading 0 of national numbers, e.g. "49" turns
+	// 0891234567 into +49891234567.
+	DefaultCountryCode string
+	// Sheet (optional) is the name of the XLSX worksheet, by default the first one.
+	Sheet string
+}
+
+// Row is a valid recipient of a list.
+type Row struct {
+	// Line is the line of the row in the file, starting at 1.
+	Line int
+	// Number is the normalized number.
+	Number string
+	// CustomerRef is the customer reference, if mapped.
+	CustomerRef string
+	// AlternativeNumbers are the normalized alternative numbers, if mapped.
+	AlternativeNumbers []string
+	// Variables are the other columns, by header name or by 1-based position without header.
+	Variables map[string]string
+}
+
+// Rejection is a row which was skipped.
+type Rejection struct {
+	// Line is the line of the row in the file, starting at 1.
+	Line int
+	// Fields are the cells of the row.
+	Fields []string
+	// Reason explains why the row was skipped.
+	Reason string
+}
+
+// List is an imported recipient list.
+type List struct {
+	// Header are the column names, empty if the list has no header.
+	Header []string
+	// Rows are the valid recipients in the order of the file.
+	Rows []Row
+	// Rejected are the skipped rows in the order of the file.
+	Rejected []Rejection
+}
+
+// ReadFile reads a CSV or XLSX list, depending on the file extension.
+func ReadFile(path string, opts Options) (*List, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv", ".txt":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ReadCSV(f, opts)
+	case ".xlsx":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return ReadXLSX(f, info.Size(), opts)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFile, path)
+}
+
+// SMSRecipients returns the rows as SMS recipients.
+func (l *List) SMSRecipients() []sms.Recipient {
+	recipients := make([]sms.Recipient, len(l.Rows))
+	for i, r := range l.Rows {
+		recipients[i] = sms.NewRecipient(r.Number, r.CustomerRef, nil)
+	}
+	return recipients
+}
+
+// TemplateRecipients returns the rows as SMS recipients with their variables as template data.
+func (l *List) TemplateRecipients() []sms.TemplateRecipient {
+	recipients := make([]sms.TemplateRecipient, len(l.Rows))
+	for i, r := range l.Rows {
+		recipients[i] = sms.TemplateRecipient{Recipient: sms.NewRecipient(r.Number, r.CustomerRef, nil), Data: r.Variables}
+	}
+	return recipients
+}
+
+// FaxRecipients returns the rows as fax recipients, their variables become the properties of a
+// personalized cover page, sorted by key.
+func (l *List) FaxRecipients() []fax.Recipient {
+	recipients := make([]fax.Recipient, len(l.Rows))
+	for i, r := range l.Rows {
+		var properties []fax.RecipientProperty
+		for key, value := range r.Variables {
+			properties = append(properties, fax.RecipientProperty{Key: key, Value: value})
+		}
+		sort.Slice(properties, func(a, b int) bool { return properties[a].Key < properties[b].Key })
+		recipients[i] = fax.NewRecipient(r.Number, r.AlternativeNumbers, properties)
+	}
+	return recipients
+}
+
+// NormalizeNumber removes spaces, dashes, dots, slashes and parentheses from a number and turns
+// the international prefix 00 into +. With a defaultCountryCode, a single leading 0 is replaced
+// by it. The result must have 6 to 15 digits, the maximum of E.164.
+func NormalizeNumber(number string, defaultCountryCode string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case strings.ContainsRune(" -./()\u00a0", r):
+		default:
+			return "", fmt.Errorf("invalid character %q in number %q", r, number)
+		}
+	}
+	n := b.String()
+	switch {
+	case strings.HasPrefix(n, "00"):
+		n = "+" + n[2:]
+	case strings.HasPrefix(n, "0") && defaultCountryCode != "":
+		n = "+" + strings.TrimPrefix(defaultCountryCode, "+") + n[1:]
+	}
+	digits := len(strings.TrimPrefix(n, "+"))
+	if digits < 6 || digits > 15 {
+		return "", fmt.Errorf("number %q has %d digits", number, digits)
+	}
+	return n, nil
+}
+
+// builder turns the records of a list into rows and rejections.
+type builder struct {
+	opts   Options
+	list   *List
+	cols   columns
+	seen   map[string]int
+	ready  bool
+	header bool
+}
+
+type columns struct {
+	number, customerRef, alternatives int
+	names                             []string
+}
+
+func newBuilder(opts Options) *builder {
+	return &builder{opts: opts, list: &List{}, seen: map[string]int{}}
+}
+
+// add handles the record of a line, the first call decides about the header.
+func (b *builder) add(line int, fields []string) error {
+	if !b.ready {
+		b.ready = true
+		b.header = b.opts.Header == WithHeader || (b.opts.Header == DetectHeader && !b.hasNumber(fields))
+		if b.header {
+			b.list.Header = trimAll(fields)
+		}
+		cols, err := b.columns(fields)
+		if err != nil {
+			return err
+		}
+		b.cols = cols
+		if b.header {
+			return nil
+		}
+	}
+	if isBlank(fields) {
+		return nil
+	}
+	reject := func(reason string) {
+		b.list.Rejected = append(b.list.Rejected, Rejection{Line: line, Fields: fields, Reason: reason})
+	}
+	if b.cols.number >= len(fields) || strings.TrimSpace(fields[b.cols.number]) == "" {
+		reject("missing number")
+		return nil
+	}
+	number, err := NormalizeNumber(fields[b.cols.number], b.opts.DefaultCountryCode)
+	if err != nil {
+		reject(err.Error())
+		return nil
+	}
+	if first, ok := b.seen[number]; ok {
+		reject(fmt.Sprintf("duplicate of line %d", first))
+		return nil
+	}
+	row := Row{Line: line, Number: number, Variables: map[string]string{}}
+	if b.cols.customerRef >= 0 && b.cols.customerRef < len(fields) {
+		row.CustomerRef = strings.TrimSpace(fields[b.cols.customerRef])
+	}
+	if b.cols.alternatives >= 0 && b.cols.alternatives < len(fields) {
+		for _, alt := range strings.FieldsFunc(fields[b.cols.alternatives], func(r rune) bool { return r == ';' || r == '|' }) {
+			if strings.TrimSpace(alt) == "" {
+				continue
+			}
+			n, err := NormalizeNumber(alt, b.opts.DefaultCountryCode)
+			if err != nil {
+				reject("alternative " + err.Error())
+				return nil
+			}
+			row.AlternativeNumbers = append(row.AlternativeNumbers, n)
+		}
+	}
+	for i, value := range fields {
+		if i == b.cols.number || i == b.cols.customerRef || i == b.cols.alternatives {
+			continue
+		}
+		row.Variables[b.cols.name(i)] = strings.TrimSpace(value)
+	}
+	b.seen[number] = line
+	b.list.Rows = append(b.list.Rows, row)
+	return nil
+}
+
+// hasNumber reports whether a cell of the record is a phone number.
+func (b *builder) hasNumber(fields []string) bool {
+	for _, f := range fields {
+		if _, err := NormalizeNumber(f, b.opts.DefaultCountryCode); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// columns resolves the mapped columns with the first record of the list.
+func (b *builder) columns(first []string) (columns, error) {
+	cols := columns{names: b.list.Header}
+	var err error
+	if cols.number, err = b.column(b.opts.Number); err != nil {
+		return cols, err
+	}
+	if b.opts.Number == "" {
+		cols.number = b.defaultNumberColumn(first)
+	}
+	if cols.customerRef, err = b.column(b.opts.CustomerRef); err != nil {
+		return cols, err
+	}
+	if cols.alternatives, err = b.column(b.opts.AlternativeNumbers); err != nil {
+		return cols, err
+	}
+	return cols, nil
+}
+
+// column returns the index of a mapped column, -1 if it isn't mapped.
+func (b *builder) column(name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	for i, h := range b.list.Header {
+		if strings.EqualFold(h, name) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return n - 1, nil
+	}
+	return -1, fmt.Errorf("column %q not found in %v", name, b.list.Header)
+}
+
+// defaultNumberColumn returns the first column with a header like "number", or without header
+// the first column of the first record with an international number starting with + or 00, so
+// IDs and other numeric cells aren't taken for the number. Without an international number the
+// first column with any number is used.
+func (b *builder) defaultNumberColumn(first []string) int {
+	for _, candidate := range numberHeaders {
+		for i, h := range b.list.Header {
+			if strings.EqualFold(strings.NewReplacer("_", "", "-", "", " ", "").Replace(h), candidate) {
+				return i
+			}
+		}
+	}
+	if !b.header {
+		for _, international := range []bool{true, false} {
+			for i, f := range first {
+				f = strings.TrimSpace(f)
+				if international && !strings.HasPrefix(f, "+") && !strings.HasPrefix(f, "00") {
+					continue
+				}
+				if _, err := NormalizeNumber(f, b.opts.DefaultCountryCode); err == nil {
+					return i
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// name returns the variable name of a column, its header or its 1-based position.
+func (c columns) name(i int) string {
+	if i < len(c.names) && c.names[i] != "" {
+		return c.names[i]
+	}
+	return strconv.Itoa(i + 1)
+}
+
+func trimAll(fields []string) []string {
+	trimmed := make([]string, len(fields))
+	for i, f := range fields {
+		trimmed[i] = strings.TrimSpace(f)
+	}
+	return trimmed
+}
+
+func isBlank(fields []string) bool {
+	for _, f := range fields {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package importer
+
+import (
+	"github.com/retarus/retarus-go/fax"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSVWithHeader(t *testing.T) {
+	data := "\xef\xbb\xbfid;first_name;Mobile;ref\n" +
+		"1;Moll;+49 176 1234-5678;parcel-1\n" +
+		"2;Sibelle;0176 2345678;parcel-2\n" +
+		"3;Kath;;parcel-3\n" +
+		"4;Orazio;call me;parcel-4\n" +
+		"\n" +
+		"5;Garald;0049 176 12345678;parcel-5\n"
+	list, err := ReadCSV(strings.NewReader(data), Options{CustomerRef: "ref", DefaultCountryCode: "49"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list.Header, []string{"id", "first_name", "Mobile", "ref"}) {
+		t.Errorf("unexpected header %v", list.Header)
+	}
+	want := []Row{
+		{Line: 2, Number: "+4917612345678", CustomerRef: "parcel-1", Variables: map[string]string{"id": "1", "first_name": "Moll"}},
+		{Line: 3, Number: "+491762345678", CustomerRef: "parcel-2", Variables: map[string]string{"id": "2", "first_name": "Sibelle"}},
+	}
+	if !reflect.DeepEqual(list.Rows, want) {
+		t.Errorf("expected %+v, got %+v", want, list.Rows)
+	}
+	var rejected []int
+	for _, r := range list.Rejected {
+		rejected = append(rejected, r.Line)
+	}
+	if !reflect.DeepEqual(rejected, []int{4, 5, 7}) {
+		t.Errorf("expected lines 4, 5 and 7 to be rejected, got %+v", list.Rejected)
+	}
+	if !strings.Contains(list.Rejected[2].Reason, "duplicate of line 2") {
+		t.Errorf("expected a duplicate, got %q", list.Rejected[2].Reason)
+	}
+}
+
+func TestReadCSVWithoutHeader(t *testing.T) {
+	data := "Axel,+4917600000001,+4989000000001|+4989000000002\nBerta,\"+49 176 00000002\",\n"
+	list, err := ReadCSV(strings.NewReader(data), Options{Number: "2", AlternativeNumbers: "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Header) != 0 || len(list.Rows) != 2 || len(list.Rejected) != 0 {
+		t.Fatalf("expected two rows without header, got %+v", list)
+	}
+	recipients := list.FaxRecipients()
+	if recipients[0].Number != "+4917600000001" || !reflect.DeepEqual(recipients[0].AlternativeNumbers, []string{"+4989000000001", "+4989000000002"}) {
+		t.Errorf("unexpected recipient %+v", recipients[0])
+	}
+	if len(recipients[1].Properties) != 1 || recipients[1].Properties[0] != (fax.RecipientProperty{Key: "1", Value: "Berta"}) {
+		t.Errorf("unexpected properties %+v", recipients[1].Properties)
+	}
+
+	if _, err := ReadCSV(strings.NewReader(data), Options{Number: "phone"}); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+func TestReadCSVDetectsNumberColumn(t *testing.T) {
+	data := "100001,Axel,+4917600000001\n100002,Berta,0049 176 00000002\n"
+	list, err := ReadCSV(strings.NewReader(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rows) != 2 || list.Rows[0].Number != "+4917600000001" || list.Rows[1].Number != "+4917600000002" {
+		t.Errorf("expected the international numbers, got %+v", list.Rows)
+	}
+	if list.Rows[0].Variables["1"] != "100001" {
+		t.Errorf("expected the ID as a variable, got %+v", list.Rows[0].Variables)
+	}
+}
+
+func TestReadCSVRejectsMalformedQuotes(t *testing.T) {
+	data := "number\n+4917600000001\n\"x\"y\nbare\"quote\n+4917600000002\n"
+	list, err := ReadCSV(strings.NewReader(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rows) != 2 || list.Rows[1].Line != 5 {
+		t.Errorf("expected the rows around the malformed ones, got %+v", list.Rows)
+	}
+	if len(list.Rejected) != 2 || list.Rejected[0].Line != 3 || list.Rejected[1].Line != 4 {
+		t.Errorf("expected lines 3 and 4 to be rejected, got %+v", list.Rejected)
+	}
+}
+
+func TestReadExampleCampaign(t *testing.T) {
+	// the options of examples/sms/02.bulk_sms_sender.go
+	list, err := ReadFile("../examples/sms/assets/sms_data.csv", Options{Number: "number", CustomerRef: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rows) != 20 || len(list.Rejected) != 0 {
+		t.Fatalf("expected 20 recipients without rejections, got %d and %+v", len(list.Rows), list.Rejected)
+	}
+	recipients := list.TemplateRecipients()
+	if recipients[0].Dst != "+50428230083" || recipients[0].CustomerRef != "1" || recipients[0].Data.(map[string]string)["first_name"] != "Moll" {
+		t.Errorf("unexpected first recipient %+v", recipients[0])
+	}
+	detected, err := ReadFile("../examples/sms/assets/sms_data.csv", Options{})
+	if err != nil || detected.TemplateRecipients()[0].Dst != recipients[0].Dst {
+		t.Errorf("expected the number column to be detected, got %+v, %v", detected, err)
+	}
+	if _, err := ReadFile("contacts.json", Options{}); err == nil {
+		t.Error("expected an error for an unsupported file")
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	for in, want := range map[string]string{
+		"+49 (89) 123 456-78": "+498912345678",
+		"0049.89.12345678":    "+498912345678",
+		"089/12345678":        "+498912345678",
+		"4989 12345678":       "498912345678",
+	} {
+		if got, err := NormalizeNumber(in, "49"); err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s %v", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"", "12345", "+49 89 CALL NOW", "49+8912345678", "+4989123456789012"} {
+		if got, err := NormalizeNumber(in, ""); err == nil {
+			t.Errorf("%q should be invalid, got %s", in, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrSheetNotFound is returned by ReadXLSX if the workbook has no sheet with the name of
+// Options.Sheet.
+var ErrSheetNotFound = errors.New("worksheet not found")
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a shared or inline string, either plain or rich text with several runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX reads a recipient list from the first worksheet of an Excel workbook, or from
+// Options.Sheet. Cells are read as their stored values, numbers without their display format,
+// so phone numbers should be stored as text. Line is the row number of the sheet.
+func ReadXLSX(r io.ReaderAt, size int64, opts Options) (*List, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := sheetPath(zr, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if err := decodeZipXML(zr, "xl/sharedStrings.xml", &shared); err != nil && !errors.Is(err, errNoZipFile) {
+		return nil, err
+	}
+	var ws xlsxWorksheet
+	if err := decodeZipXML(zr, sheet, &ws); err != nil {
+		return nil, err
+	}
+
+	b := newBuilder(opts)
+	for i, row := range ws.Rows {
+		line := row.R
+		if line == 0 {
+			line = i + 1
+		}
+		var fields []string
+		for j, c := range row.Cells {
+			col := columnIndex(c.R)
+			if col < 0 {
+				col = j
+			}
+			for len(fields) <= col {
+				fields = append(fields, "")
+			}
+			switch c.T {
+			case "s":
+				n, err := strconv.Atoi(c.V)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, fmt.Errorf("cell %s refers to the unknown shared string %q", c.R, c.V)
+				}
+				fields[col] = shared.Items[n].String()
+			case "inlineStr":
+				fields[col] = c.Inline.String()
+			case "b":
+				fields[col] = map[string]string{"0": "FALSE", "1": "TRUE"}[c.V]
+			case "", "n":
+				fields[col] = numberCell(c.V)
+			default:
+				fields[col] = c.V
+			}
+		}
+		if err := b.add(line, fields); err != nil {
+			return nil, err
+		}
+	}
+	return b.list, nil
+}
+
+// sheetPath returns the path of the worksheet with the given name in the archive, or of the
+// first worksheet if name is empty.
+func sheetPath(zr *zip.Reader, name string) (string, error) {
+	var wb xlsxWorkbook
+	if err := decodeZipXML(zr, "xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+	var rels xlsxRelationships
+	if err := decodeZipXML(zr, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	for _, s := range wb.Sheets {
+		if name != "" && s.Name != name {
+			continue
+		}
+		for _, rel := range rels.Relationships {
+			if rel.ID != s.ID {
+				continue
+			}
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return path.Join("xl", rel.Target), nil
+		}
+		return "", fmt.Errorf("worksheet %q has no relationship %q", s.Name, s.ID)
+	}
+	if name == "" {
+		return "", fmt.Errorf("%w: the workbook has no sheets", ErrSheetNotFound)
+	}
+	return "", fmt.Errorf("%w: %q", ErrSheetNotFound, name)
+}
+
+var errNoZipFile = errors.New("file not found in the workbook")
+
+func decodeZipXML(zr *zip.Reader, name string, v any) error {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(rc).Decode(v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errNoZipFile, name)
+}
+
+// columnIndex returns the 0-based column of a cell reference like "AB12".
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+// numberCell formats a numeric cell without exponent, large numbers like phone numbers are often
+// stored as 4.9176E+12.
+func numberCell(v string) string {
+	if !strings.ContainsAny(v, "eE") {
+		return v
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
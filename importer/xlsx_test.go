@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// workbook returns an XLSX file with the given worksheets, in the layout Excel writes them.
+func workbook(t *testing.T, sheets map[string]string, shared string) []byte {
+	t.Helper()
+	files := map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + shared + `</sst>`,
+	}
+	wb := `<?xml version="1.0" encoding="UTF-8"?><workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`
+	rels := `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`
+	for i, name := range []string{"Contacts", "Archive"} {
+		data, ok := sheets[name]
+		if !ok {
+			continue
+		}
+		id := string(rune('1' + i))
+		wb += `<sheet name="` + name + `" sheetId="` + id + `" r:id="rId` + id + `"/>`
+		rels += `<Relationship Id="rId` + id + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + id + `.xml"/>`
+		files["xl/worksheets/sheet"+id+".xml"] = `<?xml version="1.0" encoding="UTF-8"?>` +
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + data + `</sheetData></worksheet>`
+	}
+	files["xl/workbook.xml"] = wb + `</sheets></workbook>`
+	files["xl/_rels/workbook.xml.rels"] = rels + `</Relationships>`
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	shared := `<si><t>name</t></si><si><t>number</t></si><si><r><t>Ax</t></r><r><t>el</t></r></si><si><t>+49 176 00000001</t></si>`
+	contacts := `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>` +
+		`<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="s"><v>3</v></c></row>` +
+		`<row r="4"><c r="A4" t="inlineStr"><is><t>Berta</t></is></c><c r="B4"><v>4.917600000002E+12</v></c></row>` +
+		`<row r="5"><c r="B5"><v>12</v></c></row>`
+	archive := `<row r="1"><c r="C1" t="inlineStr"><is><t>+4917600000009</t></is></c></row>`
+	data := workbook(t, map[string]string{"Contacts": contacts, "Archive": archive}, shared)
+
+	list, err := ReadXLSX(bytes.NewReader(data), int64(len(data)), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{Line: 2, Number: "+4917600000001", Variables: map[string]string{"name": "Axel"}},
+		{Line: 4, Number: "4917600000002", Variables: map[string]string{"name": "Berta"}},
+	}
+	if !reflect.DeepEqual(list.Rows, want) {
+		t.Errorf("expected %+v, got %+v", want, list.Rows)
+	}
+	if len(list.Rejected) != 1 || list.Rejected[0].Line != 5 {
+		t.Errorf("expected row 5 to be rejected, got %+v", list.Rejected)
+	}
+
+	list, err = ReadXLSX(bytes.NewReader(data), int64(len(data)), Options{Sheet: "Archive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rows) != 1 || list.Rows[0].Number != "+4917600000009" || len(list.Header) != 0 {
+		t.Errorf("expected the recipient of the second sheet, got %+v", list)
+	}
+	if _, err := ReadXLSX(bytes.NewReader(data), int64(len(data)), Options{Sheet: "Missing"}); !errors.Is(err, ErrSheetNotFound) {
+		t.Errorf("expected ErrSheetNotFound, got %v", err)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "B12": 1, "Z3": 25, "AA1": 26, "AB100": 27} {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("%s: expected %d, got %d", ref, want, got)
+		}
+	}
+}
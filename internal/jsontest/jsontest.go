@@ -0,0 +1,33 @@
+// Package jsontest holds the JSON assertions shared by the tests of the fax and sms packages.
+package jsontest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// AssertRoundTrip decodes testdata/<fixture> of the tested package into v, encodes it again and
+// compares both documents.
+func AssertRoundTrip(t testing.TB, fixture string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", fixture, err)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: %v", fixture, err)
+	}
+	var want, got any
+	json.Unmarshal(data, &want)
+	json.Unmarshal(encoded, &got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s changed in the round trip:\n%s", fixture, encoded)
+	}
+}
@@ -0,0 +1,90 @@
+// Package prometheus provides a common.Metrics implementation which exports the measurements of
+// the SMS and fax clients as Prometheus metrics.
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/retarus/retarus-go/common"
+)
+
+// Metrics implements common.Metrics with Prometheus collectors.
+// Note: To create a new instance of Metrics, use the NewMetrics function.
+type Metrics struct {
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	smsParts   prometheus.Counter
+	faxPages   prometheus.Counter
+	pollingLag *prometheus.HistogramVec
+}
+
+var _ common.Metrics = (*Metrics)(nil)
+
+// NewMetrics creates the collectors and registers them with reg, e.g. prometheus.DefaultRegisterer.
+//
+// The following metrics are exported:
+//   - retarus_requests_total{service, operation, datacenter, status_code, error_class}
+//   - retarus_request_duration_seconds{service, operation, datacenter}
+//   - retarus_sms_parts_submitted_total
+//   - retarus_fax_pages_submitted_total
+//   - retarus_report_polling_lag_seconds{service}
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "retarus",
+			Name:      "requests_total",
+			Help:      "Number of requests sent to Retarus, by outcome.",
+		}, []string{"service", "operation", "datacenter", "status_code", "error_class"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "retarus",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests sent to Retarus.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"service", "operation", "datacenter"}),
+		smsParts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "retarus",
+			Name:      "sms_parts_submitted_total",
+			Help:      "Number of SMS parts of successfully submitted jobs.",
+		}),
+		faxPages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "retarus",
+			Name:      "fax_pages_submitted_total",
+			Help:      "Number of fax pages of successfully submitted jobs, over all recipients.",
+		}),
+		pollingLag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "retarus",
+			Name:      "report_polling_lag_seconds",
+			Help:      "Time between the completion of a job and the fetch of its report.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 300, 900, 3600},
+		}, []string{"service"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.latency, m.smsParts, m.faxPages, m.pollingLag} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveRequest implements common.Metrics.
+func (m *Metrics) ObserveRequest(o common.RequestObservation) {
+	m.requests.WithLabelValues(o.Service, o.Operation, o.Datacenter, strconv.Itoa(o.StatusCode), string(o.ErrorClass)).Inc()
+	m.latency.WithLabelValues(o.Service, o.Operation, o.Datacenter).Observe(o.Duration.Seconds())
+}
+
+// AddSMSParts implements common.Metrics.
+func (m *Metrics) AddSMSParts(parts int) {
+	m.smsParts.Add(float64(parts))
+}
+
+// AddFaxPages implements common.Metrics.
+func (m *Metrics) AddFaxPages(pages int) {
+	m.faxPages.Add(float64(pages))
+}
+
+// ObservePollingLag implements common.Metrics.
+func (m *Metrics) ObservePollingLag(service string, lag time.Duration) {
+	m.pollingLag.WithLabelValues(service).Observe(lag.Seconds())
+}
@@ -0,0 +1,47 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/retarus/retarus-go/common"
+)
+
+func TestMetricsRecordsDatacenterRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dc1/jobs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := common.NewTransporter(5)
+	ts.Metrics = m
+
+	dc1, dc2 := server.URL+"/dc1", server.URL+"/dc2"
+	ts.DoDatacenterFetch([]string{dc1, dc2}, "", "", nil, "/jobs", http.MethodGet)
+	m.AddSMSParts(3)
+
+	if v := testutil.ToFloat64(m.requests.WithLabelValues("", "", dc1, "404", "not_found")); v != 1 {
+		t.Errorf("expected one not found request, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.requests.WithLabelValues("", "", dc2, "500", "server_error")); v != 1 {
+		t.Errorf("expected one server error, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.smsParts); v != 3 {
+		t.Errorf("expected 3 sms parts, got %v", v)
+	}
+	if n := testutil.CollectAndCount(m.latency); n != 2 {
+		t.Errorf("expected latencies for two datacenters, got %d", n)
+	}
+}
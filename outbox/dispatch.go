@@ -0,0 +1,255 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+	"github.com/retarus/retarus-go/fax"
+	"github.com/retarus/retarus-go/sms"
+)
+
+// Run calls Dispatch every Interval, and right after an entry was enqueued, until ctx is done.
+// Errors of writing the log are retried with the next dispatch.
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.opts.Interval)
+	defer ticker.Stop()
+	for {
+		o.Dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// Dispatch sends the pending entries and polls the status of the sent entries which are due, with
+// Workers in parallel, and waits until they are processed. Errors of sends and polls are recorded
+// in the entries, the returned error joins the errors of writing the log.
+func (o *Outbox) Dispatch(ctx context.Context) error {
+	o.dispatchMu.Lock()
+	defer o.dispatchMu.Unlock()
+
+	due := o.due()
+	work := make(chan Entry)
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for w := 0; w < min(o.opts.Workers, len(due)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range work {
+				if err := o.process(ctx, e); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("entry %s: %w", e.ID, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, e := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// due returns copies of the unfinished entries whose Next time has come, in the order they were
+// enqueued.
+func (o *Outbox) due() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := o.now()
+	var due []Entry
+	for _, e := range o.sorted() {
+		if !e.State.Finished() && !now.Before(e.Next) {
+			due = append(due, *e)
+		}
+	}
+	return due
+}
+
+func (o *Outbox) process(ctx context.Context, e Entry) error {
+	switch e.State {
+	case Pending:
+		return o.send(ctx, e)
+	case Sent:
+		return o.poll(ctx, e)
+	}
+	return nil
+}
+
+// send attempts to send the job of a pending entry. The attempt is written to the log before, so
+// an attempt which is interrupted by a crash counts and is retried after the retry delay. While
+// the idempotency key of the entry is still claimed by such an attempt, the retries don't count,
+// as the claim may only be released when its lease expires. A retry which the service rejects as
+// duplicate of a job sent by an earlier attempt finishes the entry with StatusUnknown.
+func (o *Outbox) send(ctx context.Context, e Entry) error {
+	e.Attempts++
+	e.Next = o.now().Add(o.retryDelay(e.Attempts))
+	if err := o.save(e, true); err != nil {
+		return err
+	}
+
+	jobID, suppressed, err := o.sendJob(ctx, e)
+	now := o.now()
+	switch {
+	case err == nil:
+		e.State, e.JobID, e.SentAt, e.Error = Sent, jobID, now, ""
+		e.Recipients = suppressed
+		e.Next = now.Add(o.opts.PollInterval)
+	case errors.Is(err, common.ErrIdempotencyKeyInUse):
+		e.Attempts--
+		e.Error = err.Error()
+	case errors.Is(err, sms.ErrDuplicateJob) && e.Attempts > 1:
+		e.State, e.Status, e.Error = Done, StatusUnknown, err.Error()
+		e.Recipients = suppressed
+	case !o.opts.Retryable(err) || e.Attempts >= o.opts.MaxAttempts:
+		e.State, e.Error = Failed, err.Error()
+	default:
+		e.Error = err.Error()
+	}
+	return o.save(e, true)
+}
+
+// retryDelay returns the delay after the given failed attempt.
+func (o *Outbox) retryDelay(attempt int) time.Duration {
+	delay := o.opts.RetryDelay
+	for i := 1; i < attempt && delay < o.opts.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, o.opts.MaxRetryDelay)
+}
+
+// sendJob sends the job of the entry with the entry ID as idempotency key, if the client has an
+// idempotency store, and returns the recipients which were suppressed.
+func (o *Outbox) sendJob(ctx context.Context, e Entry) (string, []RecipientStatus, error) {
+	var statuses []RecipientStatus
+	switch {
+	case e.Kind == SMS && o.opts.SMS != nil && e.SMS != nil:
+		var opts sms.SendOptions
+		if o.opts.SMS.Idempotency != nil {
+			opts.IdempotencyKey = e.ID
+		}
+		result, err := o.opts.SMS.SendWithOptions(ctx, *e.SMS, opts)
+		for _, s := range result.Suppressed {
+			statuses = append(statuses, RecipientStatus{Number: s.Recipient.Dst, Status: Suppressed, Reason: string(s.Entry.Reason)})
+		}
+		return result.JobID, statuses, err
+	case e.Kind == Fax && o.opts.Fax != nil && e.Fax != nil:
+		var opts fax.SendOptions
+		if o.opts.Fax.Idempotency != nil {
+			opts.IdempotencyKey = e.ID
+		}
+		result, err := o.opts.Fax.SendWithOptions(ctx, *e.Fax, opts)
+		for _, s := range result.Suppressed {
+			statuses = append(statuses, RecipientStatus{Number: s.Number, Status: Suppressed, Reason: string(s.Entry.Reason)})
+		}
+		return result.JobID, statuses, err
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrNoClient, e.Kind)
+}
+
+// poll fetches the status of a sent job. Polls which don't change the entry aren't written to
+// the log, after a restart the entry is polled right away.
+func (o *Outbox) poll(ctx context.Context, e Entry) error {
+	statuses, finished, err := o.status(ctx, e)
+	now := o.now()
+	switch {
+	case err == nil && finished:
+		e.State, e.Error = Done, ""
+		e.Recipients = append(e.Recipients, statuses...)
+		e.Status = overallStatus(statuses)
+		return o.save(e, true)
+	case now.Sub(e.SentAt) >= o.opts.StatusTimeout:
+		if err == nil {
+			err = fmt.Errorf("job %s has no final status after %s", e.JobID, o.opts.StatusTimeout)
+		}
+		e.State, e.Error = Failed, err.Error()
+		return o.save(e, true)
+	}
+	e.Next = now.Add(o.opts.PollInterval)
+	if err != nil && err.Error() != e.Error {
+		e.Error = err.Error()
+		return o.save(e, true)
+	}
+	return o.save(e, false)
+}
+
+// status returns the statuses of the recipients of a sent job, finished is false as long as the
+// job is processed.
+func (o *Outbox) status(ctx context.Context, e Entry) (statuses []RecipientStatus, finished bool, err error) {
+	switch {
+	case e.Kind == SMS && o.opts.SMS != nil:
+		report, err := o.opts.SMS.GetReportContext(ctx, e.JobID)
+		if err != nil || report.FinishedTS == nil {
+			return nil, false, err
+		}
+		smsStatuses, err := o.opts.SMS.GetSmsStatusContext(ctx, e.JobID)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, s := range *smsStatuses {
+			statuses = append(statuses, RecipientStatus{Number: s.Dst, Status: s.Status, Reason: s.Reason})
+		}
+		return statuses, true, nil
+	case e.Kind == Fax && o.opts.Fax != nil:
+		report, err := o.opts.Fax.GetReportContext(ctx, e.JobID)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, s := range report.RecipientStatus {
+			if !s.Finished() {
+				return nil, false, nil
+			}
+			statuses = append(statuses, RecipientStatus{Number: s.Number, Status: s.Status, Reason: s.Reason})
+		}
+		return statuses, len(statuses) > 0, nil
+	}
+	return nil, false, fmt.Errorf("%w: %s", ErrNoClient, e.Kind)
+}
+
+// overallStatus summarizes the statuses of the recipients.
+func overallStatus(statuses []RecipientStatus) string {
+	ok := 0
+	for _, s := range statuses {
+		if strings.EqualFold(s.Status, "OK") {
+			ok++
+		}
+	}
+	switch {
+	case ok > 0 && ok == len(statuses):
+		return StatusOK
+	case ok > 0:
+		return StatusPartial
+	}
+	return StatusFailed
+}
+
+// save replaces the entry in the outbox and, if persist is true, writes it to the log. The entry
+// is replaced even if it can't be written, so a sent job isn't sent again by this process. OnFinish
+// is called once the entry is finished.
+func (o *Outbox) save(e Entry, persist bool) error {
+	o.mu.Lock()
+	var err error
+	if persist {
+		err = o.update(&e)
+	}
+	*o.entries[e.ID] = e
+	o.mu.Unlock()
+	if e.State.Finished() && o.opts.OnFinish != nil {
+		o.opts.OnFinish(e)
+	}
+	return err
+}
@@ -0,0 +1,468 @@
+// Package outbox persists SMS and fax jobs on disk before they are sent, so no job is lost if the
+// process dies.
+//
+// Every job is appended to a log file as an Entry, and Run sends the pending entries with a pool
+// of workers, retries failed sends with a growing delay and polls the reports of sent jobs until
+// their final status is known. When the outbox is opened again after a restart, unsent and
+// unconfirmed entries are picked up where they were left:
+//
+//	box, err := outbox.Open("outbox.jsonl", outbox.Options{SMS: &smsClient, Fax: &faxClient})
+//	defer box.Close()
+//	go box.Run(ctx)
+//	id, err := box.EnqueueFax("", job)
+//
+// A job whose send was interrupted by a crash is sent again. Give the clients an
+// IdempotencyStore which survives restarts, like common.FileIdempotencyStore, and the entry ID is
+// used as idempotency key, so the job isn't sent twice.
+package outbox
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+	"github.com/retarus/retarus-go/fax"
+	"github.com/retarus/retarus-go/sms"
+)
+
+// Default settings of Options.
+const (
+	DefaultWorkers       = 4
+	DefaultMaxAttempts   = 5
+	DefaultRetryDelay    = 30 * time.Second
+	DefaultMaxRetryDelay = 30 * time.Minute
+	DefaultPollInterval  = time.Minute
+	DefaultStatusTimeout = 72 * time.Hour
+	DefaultRetention     = 7 * 24 * time.Hour
+	DefaultInterval      = time.Second
+)
+
+var (
+	// ErrNoClient is the error of an entry whose kind has no client in the Options.
+	ErrNoClient = errors.New("outbox has no client for this kind of job")
+	// ErrClosed is returned for entries enqueued after Close.
+	ErrClosed = errors.New("outbox is closed")
+)
+
+// Kind is the service of an entry.
+type Kind string
+
+const (
+	SMS Kind = "sms"
+	Fax Kind = "fax"
+)
+
+// State is the progress of an entry.
+type State string
+
+const (
+	// Pending entries wait for their next send attempt.
+	Pending State = "PENDING"
+	// Sent entries were accepted by the service and wait for their final status.
+	Sent State = "SENT"
+	// Done entries have their final status.
+	Done State = "DONE"
+	// Failed entries couldn't be sent, or their status couldn't be fetched until StatusTimeout.
+	Failed State = "FAILED"
+)
+
+// Finished reports whether the state is final.
+func (s State) Finished() bool {
+	return s == Done || s == Failed
+}
+
+// Overall statuses of a Done entry.
+const (
+	StatusOK      = "OK"
+	StatusPartial = "PARTIAL"
+	StatusFailed  = "FAILED"
+	// StatusUnknown is the status of an entry whose job was created by an attempt without
+	// response, the service rejected the retry as duplicate, so the job ID is unknown.
+	StatusUnknown = "UNKNOWN"
+)
+
+// Suppressed is the RecipientStatus.Status of recipients which were removed from a job by the
+// suppression list of the client.
+const Suppressed = "SUPPRESSED"
+
+// RecipientStatus is the final status of a recipient of an entry.
+type RecipientStatus struct {
+	Number string `json:"number"`
+	// Status is the status of the report, or Suppressed.
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Entry is a job in the outbox.
+type Entry struct {
+	ID    string `json:"id"`
+	Kind  Kind   `json:"kind"`
+	State State  `json:"state"`
+	// Attempts counts the send attempts, including one which was interrupted by a crash, but not
+	// the ones which waited for the idempotency key of such an attempt.
+	Attempts int `json:"attempts,omitempty"`
+	// JobID is the ID of the sent job.
+	JobID string `json:"jobId,omitempty"`
+	// Status is StatusOK, StatusPartial, StatusFailed or StatusUnknown once the entry is Done.
+	Status     string            `json:"status,omitempty"`
+	Recipients []RecipientStatus `json:"recipients,omitempty"`
+	// Error is the error of the last attempt, of sending or of fetching the status.
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	SentAt  time.Time `json:"sentAt,omitempty"`
+	// Next is the time of the next send attempt or status poll.
+	Next time.Time `json:"next,omitempty"`
+
+	// SMS or Fax is the job, nil once the entry is finished.
+	SMS *sms.Job `json:"-"`
+	Fax *fax.Job `json:"-"`
+}
+
+// record is a line of the log: a new entry with its job, or a later state of the entry without.
+type record struct {
+	Entry
+	SMS *sms.Job `json:"sms,omitempty"`
+	Fax *fax.Job `json:"fax,omitempty"`
+}
+
+// Options configure an Outbox.
+type Options struct {
+	// SMS and Fax are the clients the jobs are sent with. An entry of a kind without client
+	// fails with ErrNoClient.
+	SMS *sms.Client
+	Fax *fax.Client
+	// Workers is the number of entries processed in parallel, default DefaultWorkers.
+	Workers int
+	// MaxAttempts is the number of send attempts of an entry, default DefaultMaxAttempts.
+	MaxAttempts int
+	// RetryDelay is the delay after the first failed attempt, it doubles with every further
+	// attempt up to MaxRetryDelay. Defaults DefaultRetryDelay and DefaultMaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Retryable (optional) reports whether a failed send is attempted again, by default every
+	// error except the ones of IsPermanent.
+	Retryable func(err error) bool
+	// PollInterval is the time between two status polls of a sent job, default
+	// DefaultPollInterval.
+	PollInterval time.Duration
+	// StatusTimeout is how long the status of a sent job is polled before the entry fails,
+	// default DefaultStatusTimeout.
+	StatusTimeout time.Duration
+	// Retention is how long finished entries are kept in the log, default DefaultRetention.
+	// They are removed when the outbox is opened.
+	Retention time.Duration
+	// Interval is the time between two Dispatch calls of Run, default DefaultInterval. Run
+	// dispatches new entries immediately.
+	Interval time.Duration
+	// OnFinish (optional) is called when an entry is Done or Failed, by the workers concurrently.
+	OnFinish func(Entry)
+}
+
+// IsPermanent reports whether a send error won't go away by sending the job again: the job was
+// rejected as invalid or duplicate, the credentials are wrong or every recipient is suppressed.
+func IsPermanent(err error) bool {
+	for _, permanent := range []error{
+		fax.ErrBadRequest, fax.ErrAuthFailure, fax.ErrConflict, fax.ErrEmptyDocument,
+		sms.ErrDuplicateJob, common.ErrSuppressed, common.ErrAllSuppressed,
+		common.ErrNoIdempotencyStore, ErrNoClient,
+	} {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Outbox is a durable queue of SMS and fax jobs.
+// Note: To create a new instance of Outbox, use the Open function.
+type Outbox struct {
+	opts Options
+	now  func() time.Time
+	wake chan struct{}
+
+	dispatchMu sync.Mutex
+	mu         sync.Mutex
+	file       *os.File
+	entries    map[string]*Entry
+}
+
+// Open opens or creates the log file of an outbox and loads its entries. Finished entries older
+// than Retention are removed from the file.
+func Open(path string, opts Options) (*Outbox, error) {
+	return open(path, opts, time.Now)
+}
+
+func open(path string, opts Options, now func() time.Time) (*Outbox, error) {
+	opts = withDefaults(opts)
+	o := &Outbox{opts: opts, now: now, wake: make(chan struct{}, 1), entries: map[string]*Entry{}}
+	if err := o.load(path); err != nil {
+		return nil, err
+	}
+	if err := o.compact(path); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = DefaultMaxRetryDelay
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = func(err error) bool { return !IsPermanent(err) }
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.StatusTimeout <= 0 {
+		opts.StatusTimeout = DefaultStatusTimeout
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	return opts
+}
+
+// load replays the log, a truncated last line of a crash during a write is ignored.
+func (o *Outbox) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a line without newline wasn't written completely
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(data) <= 1 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		e := rec.Entry
+		if prev, ok := o.entries[e.ID]; ok {
+			e.SMS, e.Fax = prev.SMS, prev.Fax
+		}
+		if rec.SMS != nil || rec.Fax != nil {
+			e.SMS, e.Fax = rec.SMS, rec.Fax
+		}
+		if e.State.Finished() {
+			e.SMS, e.Fax = nil, nil
+		}
+		o.entries[e.ID] = &e
+	}
+}
+
+// compact rewrites the log with the entries which are kept and opens it for appending.
+func (o *Outbox) compact(path string) error {
+	now := o.now()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range o.sorted() {
+		if e.State.Finished() && now.Sub(e.Updated) >= o.opts.Retention {
+			delete(o.entries, e.ID)
+			continue
+		}
+		if err := enc.Encode(record{Entry: *e, SMS: e.SMS, Fax: e.Fax}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	o.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	return err
+}
+
+// sorted returns the entries in the order they were created.
+func (o *Outbox) sorted() []*Entry {
+	entries := make([]*Entry, 0, len(o.entries))
+	for _, e := range o.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Created.Equal(entries[j].Created) {
+			return entries[i].Created.Before(entries[j].Created)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// EnqueueSMS stores an SMS job and returns the ID of its entry. The ID can be given, e.g. the ID
+// of a queued message, a job with an ID which is already in the outbox isn't added again. An empty
+// id is generated.
+func (o *Outbox) EnqueueSMS(id string, job sms.Job) (string, error) {
+	return o.enqueue(Entry{ID: id, Kind: SMS, SMS: &job})
+}
+
+// EnqueueFax stores a fax job like EnqueueSMS. Documents with Content are read and stored with
+// their Data.
+func (o *Outbox) EnqueueFax(id string, job fax.Job) (string, error) {
+	documents := make([]fax.Document, len(job.Documents))
+	for i, d := range job.Documents {
+		if d.Content != nil {
+			data, err := io.ReadAll(d.Content)
+			if err != nil {
+				return "", err
+			}
+			d.Data, d.Content = base64.StdEncoding.EncodeToString(data), nil
+		}
+		documents[i] = d
+	}
+	job.Documents = documents
+	return o.enqueue(Entry{ID: id, Kind: Fax, Fax: &job})
+}
+
+func (o *Outbox) enqueue(e Entry) (string, error) {
+	if e.ID == "" {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return "", err
+		}
+		e.ID = hex.EncodeToString(b[:])
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return "", ErrClosed
+	}
+	if _, ok := o.entries[e.ID]; ok {
+		return e.ID, nil
+	}
+	now := o.now()
+	e.State, e.Created, e.Updated, e.Next = Pending, now, now, now
+	if err := o.write(record{Entry: e, SMS: e.SMS, Fax: e.Fax}); err != nil {
+		return "", err
+	}
+	o.entries[e.ID] = &e
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return e.ID, nil
+}
+
+// update stores the changed entry, o.mu must be held.
+func (o *Outbox) update(e *Entry) error {
+	e.Updated = o.now()
+	if e.State.Finished() {
+		e.SMS, e.Fax = nil, nil
+	}
+	return o.write(record{Entry: *e})
+}
+
+// write appends a record to the log and syncs it, o.mu must be held.
+func (o *Outbox) write(rec record) error {
+	if o.file == nil {
+		return ErrClosed
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := o.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return o.file.Sync()
+}
+
+// Entry returns a copy of the entry with the given ID.
+func (o *Outbox) Entry(id string) (Entry, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.entries[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Entries returns copies of all entries in the order they were enqueued.
+func (o *Outbox) Entries() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	sorted := o.sorted()
+	entries := make([]Entry, len(sorted))
+	for i, e := range sorted {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// Pending returns the number of entries which aren't finished.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, e := range o.entries {
+		if !e.State.Finished() {
+			n++
+		}
+	}
+	return n
+}
+
+// Close closes the log file. Entries can't be enqueued or updated afterwards, stop Run first.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
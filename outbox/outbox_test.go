@@ -0,0 +1,280 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+	"github.com/retarus/retarus-go/fax"
+	"github.com/retarus/retarus-go/retarustest"
+	"github.com/retarus/retarus-go/sms"
+)
+
+func clientsProvider(t *testing.T) (*sms.Client, *fax.Client, *retarustest.Server) {
+	srv := retarustest.NewServer(retarustest.Options{})
+	t.Cleanup(srv.Close)
+	smsClient := sms.NewClient(sms.Config{User: srv.User, Password: srv.Password, Region: srv.SMSRegion()})
+	faxClient := fax.NewClient(fax.Config{User: srv.User, Password: srv.Password, CustomerNumber: srv.CustomerNumber, Region: srv.FaxRegion()})
+	return &smsClient, &faxClient, srv
+}
+
+func testSMSJob() sms.Job {
+	return sms.NewJob([]sms.Message{sms.NewMessage("outbox test", []sms.Recipient{sms.NewRecipient("+49176000001", "", nil)})}, nil)
+}
+
+func testFaxJob() fax.Job {
+	return fax.Job{
+		Recipients: []fax.Recipient{fax.NewRecipient("+4989000001", nil, nil)},
+		Documents:  []fax.Document{{Name: "fax.txt", Content: strings.NewReader("hello")}},
+	}
+}
+
+func TestOutboxSendsAndConfirms(t *testing.T) {
+	smsClient, faxClient, srv := clientsProvider(t)
+	finished := make(chan Entry, 2)
+	box, err := open(filepath.Join(t.TempDir(), "outbox.jsonl"), Options{
+		SMS:      smsClient,
+		Fax:      faxClient,
+		OnFinish: func(e Entry) { finished <- e },
+	}, srv.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+
+	smsID, err := box.EnqueueSMS("", testSMSJob())
+	if err != nil {
+		t.Fatal(err)
+	}
+	faxID, err := box.EnqueueFax("fax-1", testFaxJob())
+	if err != nil || faxID != "fax-1" {
+		t.Fatalf("expected the given ID, got %q, %v", faxID, err)
+	}
+	if id, _ := box.EnqueueFax("fax-1", testFaxJob()); id != "fax-1" || len(box.Entries()) != 2 {
+		t.Fatal("expected an entry with a known ID not to be added again")
+	}
+
+	if err := box.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range box.Entries() {
+		if e.State != Sent || e.JobID == "" || e.Attempts != 1 {
+			t.Fatalf("expected the entry to be sent, got %+v", e)
+		}
+	}
+
+	srv.Advance(DefaultPollInterval)
+	box.Dispatch(context.Background())
+	if box.Pending() != 0 || len(finished) != 2 {
+		t.Fatalf("expected both entries to be finished, %d pending, %d finished", box.Pending(), len(finished))
+	}
+	for _, id := range []string{smsID, faxID} {
+		e, _ := box.Entry(id)
+		if e.State != Done || e.Status != StatusOK || len(e.Recipients) != 1 || e.SMS != nil || e.Fax != nil {
+			t.Errorf("unexpected finished entry %+v", e)
+		}
+	}
+}
+
+func TestOutboxRecoversAfterRestart(t *testing.T) {
+	smsClient, faxClient, srv := clientsProvider(t)
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	opts := Options{SMS: smsClient, Fax: faxClient}
+	box, err := open(path, opts, srv.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentID, _ := box.EnqueueFax("", testFaxJob())
+	box.Dispatch(context.Background())
+	unsentID, _ := box.EnqueueSMS("", testSMSJob())
+	box.Close()
+
+	// a crash during a write leaves a truncated line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"truncated","kind":"sms","sta`)
+	f.Close()
+
+	box, err = open(path, opts, srv.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+	sent, _ := box.Entry(sentID)
+	unsent, _ := box.Entry(unsentID)
+	if sent.State != Sent || sent.JobID == "" || unsent.State != Pending || unsent.SMS == nil {
+		t.Fatalf("unexpected entries after reopening: %+v, %+v", sent, unsent)
+	}
+	if _, ok := box.Entry("truncated"); ok {
+		t.Error("expected the truncated line to be ignored")
+	}
+
+	srv.Advance(DefaultPollInterval)
+	box.Dispatch(context.Background())
+	srv.Advance(DefaultPollInterval)
+	box.Dispatch(context.Background())
+	for _, e := range box.Entries() {
+		if e.State != Done {
+			t.Errorf("expected the entry to be done, got %+v", e)
+		}
+	}
+}
+
+func TestOutboxDoesNotResendInterruptedSend(t *testing.T) {
+	smsClient, _, srv := clientsProvider(t)
+	dir := t.TempDir()
+	store, err := common.OpenFileIdempotencyStore(filepath.Join(dir, "idempotency.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	smsClient.Idempotency = store
+	box, err := open(filepath.Join(dir, "outbox.jsonl"), Options{SMS: smsClient}, srv.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+
+	id, _ := box.EnqueueSMS("", testSMSJob())
+	// the job was sent, but the process died before the outbox recorded it
+	first, err := smsClient.SendWithOptions(context.Background(), testSMSJob(), sms.SendOptions{IdempotencyKey: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	box.Dispatch(context.Background())
+	if e, _ := box.Entry(id); e.State != Sent || e.JobID != first.JobID {
+		t.Errorf("expected the entry to get the job ID of the first send %s, got %+v", first.JobID, e)
+	}
+}
+
+func TestOutboxWaitsForClaimOfInterruptedSend(t *testing.T) {
+	smsClient, _, srv := clientsProvider(t)
+	store := common.NewMemoryIdempotencyStore()
+	smsClient.Idempotency = store
+	now := srv.Now()
+	box, err := open(filepath.Join(t.TempDir(), "outbox.jsonl"), Options{SMS: smsClient}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+
+	id, _ := box.EnqueueSMS("", testSMSJob())
+	// the process died after the key was claimed, before the job ID was stored
+	if _, err := store.Claim(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2*DefaultMaxAttempts; i++ {
+		box.Dispatch(context.Background())
+		e, _ := box.Entry(id)
+		if e.State != Pending || e.Attempts != 0 || !strings.Contains(e.Error, common.ErrIdempotencyKeyInUse.Error()) {
+			t.Fatalf("expected the entry to wait for the claimed key, got %+v", e)
+		}
+		now = e.Next
+	}
+
+	store.Lease = time.Nanosecond
+	box.Dispatch(context.Background())
+	if e, _ := box.Entry(id); e.State != Sent || e.Attempts != 1 || e.JobID == "" {
+		t.Fatalf("expected the entry to be sent once the lease expired, got %+v", e)
+	}
+}
+
+func TestOutboxFinishesRetryRejectedAsDuplicate(t *testing.T) {
+	smsClient, _, srv := clientsProvider(t)
+	now := srv.Now()
+	box, err := open(filepath.Join(t.TempDir(), "outbox.jsonl"), Options{SMS: smsClient}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+
+	srv.HA.FailWith(http.StatusConflict)
+	firstID, _ := box.EnqueueSMS("", testSMSJob())
+	box.Dispatch(context.Background())
+	if e, _ := box.Entry(firstID); e.State != Failed {
+		t.Fatalf("expected a duplicate of the first attempt to fail, got %+v", e)
+	}
+
+	// the first attempt of the entry timed out, the service accepted the job nevertheless
+	srv.HA.FailWith(http.StatusServiceUnavailable)
+	retriedID, _ := box.EnqueueSMS("", testSMSJob())
+	box.Dispatch(context.Background())
+	srv.HA.FailWith(http.StatusConflict)
+	now = now.Add(DefaultRetryDelay)
+	box.Dispatch(context.Background())
+	if e, _ := box.Entry(retriedID); e.State != Done || e.Status != StatusUnknown || e.Attempts != 2 {
+		t.Fatalf("expected the retry rejected as duplicate to finish the entry, got %+v", e)
+	}
+}
+
+func TestOutboxRetries(t *testing.T) {
+	smsClient, _, srv := clientsProvider(t)
+	now := srv.Now()
+	box, err := open(filepath.Join(t.TempDir(), "outbox.jsonl"), Options{SMS: smsClient, MaxAttempts: 2}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+
+	srv.HA.FailWith(http.StatusServiceUnavailable)
+	id, _ := box.EnqueueSMS("", testSMSJob())
+	box.Dispatch(context.Background())
+	e, _ := box.Entry(id)
+	if e.State != Pending || e.Attempts != 1 || e.Error == "" || !e.Next.Equal(now.Add(DefaultRetryDelay)) {
+		t.Fatalf("expected the entry to wait for a retry, got %+v", e)
+	}
+	box.Dispatch(context.Background())
+	if e, _ := box.Entry(id); e.Attempts != 1 {
+		t.Fatal("expected no attempt before the retry delay")
+	}
+
+	now = now.Add(DefaultRetryDelay)
+	box.Dispatch(context.Background())
+	if e, _ := box.Entry(id); e.State != Failed || e.Attempts != 2 {
+		t.Fatalf("expected the entry to fail after MaxAttempts, got %+v", e)
+	}
+}
+
+func TestOutboxPermanentErrors(t *testing.T) {
+	smsClient, _, srv := clientsProvider(t)
+	smsClient.Suppression = common.NewMemorySuppression(common.SuppressionEntry{Number: "+49176000001", Reason: common.OptOut})
+	box, err := open(filepath.Join(t.TempDir(), "outbox.jsonl"), Options{SMS: smsClient}, srv.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer box.Close()
+
+	suppressedID, _ := box.EnqueueSMS("", testSMSJob())
+	faxID, _ := box.EnqueueFax("", testFaxJob())
+	box.Dispatch(context.Background())
+	for _, id := range []string{suppressedID, faxID} {
+		if e, _ := box.Entry(id); e.State != Failed || e.Attempts != 1 {
+			t.Errorf("expected the entry to fail without retry, got %+v", e)
+		}
+	}
+	if e, _ := box.Entry(faxID); !strings.Contains(e.Error, ErrNoClient.Error()) {
+		t.Errorf("expected ErrNoClient, got %q", e.Error)
+	}
+	if !IsPermanent(errors.Join(errors.New("send"), common.ErrAllSuppressed)) || IsPermanent(fax.ErrServiceUnavailable) {
+		t.Error("unexpected classification of errors")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	box := &Outbox{opts: withDefaults(Options{RetryDelay: time.Minute, MaxRetryDelay: 5 * time.Minute})}
+	for attempt, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 4: 5 * time.Minute, 10: 5 * time.Minute} {
+		if got := box.retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package retarustest
+
+import (
+	"net/http"
+	"time"
+)
+
+// maxBulkJobIDs is the maximum number of job IDs per bulk report request.
+const maxBulkJobIDs = 1000
+
+// faxJobRequest is the part of a fax job request the fake evaluates.
+type faxJobRequest struct {
+	Reference *struct {
+		CustomerDefinedID string `json:"customerDefinedId,omitempty"`
+		BillingCode       string `json:"billingCode,omitempty"`
+		BillingInfo       string `json:"billingInfo,omitempty"`
+	} `json:"reference"`
+	Documents []struct {
+		Name      string `json:"name"`
+		Reference string `json:"reference"`
+		Data      string `json:"data"`
+	} `json:"documents"`
+	RenderingOptions *struct {
+		CoverpageTemplate string `json:"coverpageTemplate"`
+	} `json:"renderingOptions"`
+	Recipients []struct {
+		Number             string   `json:"number"`
+		AlternativeNumbers []string `json:"alternativeNumbers"`
+	} `json:"recipients"`
+}
+
+type faxJob struct {
+	id       string
+	body     []byte
+	request  faxJobRequest
+	accepted time.Time
+}
+
+func (dc *Datacenter) sendFax(w http.ResponseWriter, r *http.Request) {
+	var req faxJobRequest
+	body, ok := decode(w, r, &req)
+	if !ok {
+		return
+	}
+	if len(req.Recipients) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one recipient is required")
+		return
+	}
+	for _, rcpt := range req.Recipients {
+		if rcpt.Number == "" {
+			writeError(w, http.StatusBadRequest, "recipient without number")
+			return
+		}
+	}
+	for _, d := range req.Documents {
+		if d.Name == "" || (d.Data == "" && d.Reference == "") {
+			writeError(w, http.StatusBadRequest, "document needs a name and either data or a reference")
+			return
+		}
+	}
+
+	job := &faxJob{
+		id:       dc.srv.newJobID("FJ"),
+		body:     body,
+		request:  req,
+		accepted: dc.srv.Now(),
+	}
+	dc.mu.Lock()
+	dc.fax[job.id] = job
+	dc.faxOrder = append(dc.faxOrder, job.id)
+	dc.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]string{"jobId": job.id})
+}
+
+// faxReport renders the report of a job. Recipients are pending until the delivery delay has
+// passed, afterwards their status is decided by Options.FaxOutcome.
+func (dc *Datacenter) faxReport(job *faxJob) map[string]any {
+	finished := dc.finished(job.accepted)
+	recipients := []map[string]any{}
+	for _, rcpt := range job.request.Recipients {
+		status := map[string]any{
+			"number":         rcpt.Number,
+			"status":         "PENDING",
+			"reason":         "",
+			"durationInSecs": 0,
+			"sentToNumber":   "",
+			"remoteCsid":     "",
+		}
+		if finished {
+			status["status"], status["reason"] = dc.srv.options.FaxOutcome(rcpt.Number)
+			status["sentTs"] = timestamp(job.accepted.Add(dc.srv.options.DeliveryDelay))
+			status["durationInSecs"] = 30
+			status["sentToNumber"] = rcpt.Number
+		}
+		recipients = append(recipients, status)
+	}
+
+	pages := len(job.request.Documents)
+	if job.request.RenderingOptions != nil && job.request.RenderingOptions.CoverpageTemplate != "" {
+		pages++
+	}
+	report := map[string]any{
+		"jobId":           job.id,
+		"recipientStatus": recipients,
+		"pages":           pages,
+	}
+	if job.request.Reference != nil {
+		report["reference"] = job.request.Reference
+	}
+	return report
+}
+
+func (dc *Datacenter) faxJob(jobID string) *faxJob {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.fax[jobID]
+}
+
+func (dc *Datacenter) getFaxReport(w http.ResponseWriter, jobID string) {
+	job := dc.faxJob(jobID)
+	if job == nil {
+		writeError(w, http.StatusNotFound, "no report exists for job "+jobID)
+		return
+	}
+	writeJSON(w, http.StatusOK, dc.faxReport(job))
+}
+
+// completedFaxJobs returns up to 1000 finished jobs, oldest first.
+func (dc *Datacenter) completedFaxJobs() []*faxJob {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	jobs := []*faxJob{}
+	for _, id := range dc.faxOrder {
+		job := dc.fax[id]
+		if job == nil || !dc.finished(job.accepted) {
+			continue
+		}
+		jobs = append(jobs, job)
+		if len(jobs) == maxBulkJobIDs {
+			break
+		}
+	}
+	return jobs
+}
+
+func (dc *Datacenter) getFaxReports(w http.ResponseWriter) {
+	reports := []map[string]any{}
+	for _, job := range dc.completedFaxJobs() {
+		reports = append(reports, dc.faxReport(job))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"reports": reports})
+}
+
+// deleteFaxJob removes a job and returns its delete report.
+func (dc *Datacenter) deleteFaxJob(jobID string) map[string]any {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.fax[jobID] == nil {
+		return map[string]any{"jobId": jobID, "deleted": false, "reason": "NOT_FOUND"}
+	}
+	delete(dc.fax, jobID)
+	for i, id := range dc.faxOrder {
+		if id == jobID {
+			dc.faxOrder = append(dc.faxOrder[:i:i], dc.faxOrder[i+1:]...)
+			break
+		}
+	}
+	return map[string]any{"jobId": jobID, "deleted": true}
+}
+
+func (dc *Datacenter) deleteFaxReport(w http.ResponseWriter, jobID string) {
+	res := dc.deleteFaxJob(jobID)
+	if res["deleted"] == false {
+		writeJSON(w, http.StatusNotFound, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (dc *Datacenter) deleteFaxReports(w http.ResponseWriter) {
+	reports := []map[string]any{}
+	for _, job := range dc.completedFaxJobs() {
+		reports = append(reports, dc.deleteFaxJob(job.id))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"reports": reports})
+}
+
+// bulkFaxReports handles the bulk GET and DELETE actions. Unknown jobs are left out of GET
+// results and reported as NOT_FOUND by DELETE.
+func (dc *Datacenter) bulkFaxReports(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action string   `json:"action"`
+		JobIDs []string `json:"jobIds"`
+	}
+	if _, ok := decode(w, r, &req); !ok {
+		return
+	}
+	if len(req.JobIDs) > maxBulkJobIDs {
+		writeError(w, http.StatusBadRequest, "too many job ids, the maximum is 1000")
+		return
+	}
+
+	reports := []map[string]any{}
+	switch req.Action {
+	case "GET":
+		for _, id := range req.JobIDs {
+			if job := dc.faxJob(id); job != nil {
+				reports = append(reports, dc.faxReport(job))
+			}
+		}
+	case "DELETE":
+		for _, id := range req.JobIDs {
+			reports = append(reports, dc.deleteFaxJob(id))
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "unknown action "+req.Action)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"reports": reports})
+}
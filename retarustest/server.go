@@ -0,0 +1,374 @@
+// Package retarustest provides an in-process fake of the Retarus SMS and fax webservices for tests.
+//
+// A Server consists of a high availability address, which accepts new jobs, and several
+// datacenters. Like in production, a job and its reports are only visible in the datacenter which
+// accepted it, all other datacenters answer with 404. Jobs are finished after a configurable
+// delivery delay, measured on a clock which can be advanced by the test.
+//
+//	srv := retarustest.NewServer(retarustest.Options{})
+//	defer srv.Close()
+//	client := sms.NewClient(sms.Config{User: srv.User, Password: srv.Password, Region: srv.SMSRegion()})
+package retarustest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+// basePath is the path prefix of both webservices.
+const basePath = "/rest/v1"
+
+// Options configures a Server, the zero value is usable.
+type Options struct {
+	// User and Password are the credentials expected in the basic auth header, default "user" and "password".
+	User     string
+	Password string
+	// CustomerNumber is the expected customer number of fax requests, default "12345".
+	CustomerNumber string
+	// Datacenters is the number of datacenters, default 2.
+	Datacenters int
+	// DeliveryDelay is the time after which jobs are finished, default 10 seconds.
+	DeliveryDelay time.Duration
+	// FaxOutcome (optional) decides the final status and reason of a fax recipient,
+	// by default every fax is delivered with status "OK".
+	FaxOutcome func(number string) (status string, reason string)
+}
+
+// Server is a fake of the Retarus SMS and fax webservices.
+// Note: To create a new instance of Server, use the NewServer function.
+type Server struct {
+	// User, Password and CustomerNumber are the credentials the server accepts.
+	User           string
+	Password       string
+	CustomerNumber string
+	// HA is the high availability address which accepts new jobs.
+	HA *Datacenter
+	// Datacenters hold the jobs, each job is stored in exactly one of them.
+	Datacenters []*Datacenter
+
+	mu      sync.Mutex
+	options Options
+	offset  time.Duration
+	next    int
+	seq     int
+	closed  chan struct{}
+}
+
+// NewServer starts a new fake server, it must be stopped with Close.
+func NewServer(opts Options) *Server {
+	if opts.User == "" {
+		opts.User = "user"
+	}
+	if opts.Password == "" {
+		opts.Password = "password"
+	}
+	if opts.CustomerNumber == "" {
+		opts.CustomerNumber = "12345"
+	}
+	if opts.Datacenters <= 0 {
+		opts.Datacenters = 2
+	}
+	if opts.DeliveryDelay == 0 {
+		opts.DeliveryDelay = 10 * time.Second
+	}
+	if opts.FaxOutcome == nil {
+		opts.FaxOutcome = func(string) (string, string) { return "OK", "OK" }
+	}
+
+	s := &Server{
+		User:           opts.User,
+		Password:       opts.Password,
+		CustomerNumber: opts.CustomerNumber,
+		options:        opts,
+		closed:         make(chan struct{}),
+	}
+	s.HA = newDatacenter(s, "ha", s.handleHA)
+	for i := 1; i <= opts.Datacenters; i++ {
+		dc := newDatacenter(s, fmt.Sprintf("dc%d", i), nil)
+		dc.handler = dc.handleDatacenter
+		s.Datacenters = append(s.Datacenters, dc)
+	}
+	return s
+}
+
+// Close shuts down all datacenters.
+func (s *Server) Close() {
+	close(s.closed)
+	for _, dc := range append([]*Datacenter{s.HA}, s.Datacenters...) {
+		dc.server.CloseClientConnections()
+		dc.server.Close()
+	}
+}
+
+// SMSRegion returns the region to be used in a sms.Config.
+func (s *Server) SMSRegion() *common.RegionURI {
+	return s.region(basePath)
+}
+
+// FaxRegion returns the region to be used in a fax.Config.
+func (s *Server) FaxRegion() *common.RegionURI {
+	return s.region(basePath + "/")
+}
+
+func (s *Server) region(base string) *common.RegionURI {
+	servers := []string{}
+	for _, dc := range s.Datacenters {
+		servers = append(servers, dc.URL()+base)
+	}
+	uri := common.NewRegionURI(common.Europe, s.HA.URL()+base, servers)
+	return &uri
+}
+
+// Now returns the current time of the server clock.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Add(s.offset)
+}
+
+// Advance moves the server clock forward, e.g. by the delivery delay to finish all pending jobs.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset += d
+}
+
+// JobDatacenter returns the datacenter which holds the given job, or nil if the job is unknown.
+func (s *Server) JobDatacenter(jobID string) *Datacenter {
+	for _, dc := range s.Datacenters {
+		if dc.hasJob(jobID) {
+			return dc
+		}
+	}
+	return nil
+}
+
+// Job returns the request body of the SMS or fax job with the given ID, e.g. to check what a
+// client has sent. It returns nil if the job is unknown or its report was deleted.
+func (s *Server) Job(jobID string) []byte {
+	for _, dc := range s.Datacenters {
+		if body := dc.jobBody(jobID); body != nil {
+			return body
+		}
+	}
+	return nil
+}
+
+// jobBody returns the request body of a job held by the datacenter, nil if it doesn't hold it.
+func (dc *Datacenter) jobBody(jobID string) []byte {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if job := dc.sms[jobID]; job != nil {
+		return job.body
+	}
+	if job := dc.fax[jobID]; job != nil {
+		return job.body
+	}
+	return nil
+}
+
+// nextDatacenter returns the datacenter which accepts the next job, jobs are distributed round robin.
+func (s *Server) nextDatacenter() *Datacenter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dc := s.Datacenters[s.next%len(s.Datacenters)]
+	s.next++
+	return dc
+}
+
+func (s *Server) newJobID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s%08d", prefix, s.seq)
+}
+
+// handleHA accepts new jobs and stores them in the next datacenter.
+func (s *Server) handleHA(w http.ResponseWriter, r *http.Request, p string) {
+	switch {
+	case r.Method == http.MethodPost && p == "/jobs":
+		s.nextDatacenter().sendSMS(w, r)
+	case r.Method == http.MethodPost && p == "/"+s.CustomerNumber+"/fax":
+		s.nextDatacenter().sendFax(w, r)
+	case strings.HasSuffix(p, "/fax"):
+		writeError(w, http.StatusNotFound, "unknown customer number")
+	default:
+		writeError(w, http.StatusNotFound, "unknown resource "+p)
+	}
+}
+
+// Datacenter is a single datacenter of the fake, faults can be injected per datacenter.
+type Datacenter struct {
+	// Name is the name of the datacenter, e.g. "dc1".
+	Name string
+
+	srv     *Server
+	server  *httptest.Server
+	handler func(w http.ResponseWriter, r *http.Request, p string)
+
+	mu       sync.Mutex
+	latency  time.Duration
+	status   int
+	hang     bool
+	requests int
+	sms      map[string]*smsJob
+	fax      map[string]*faxJob
+	faxOrder []string
+}
+
+func newDatacenter(s *Server, name string, handler func(w http.ResponseWriter, r *http.Request, p string)) *Datacenter {
+	dc := &Datacenter{
+		Name:    name,
+		srv:     s,
+		handler: handler,
+		sms:     map[string]*smsJob{},
+		fax:     map[string]*faxJob{},
+	}
+	dc.server = httptest.NewServer(http.HandlerFunc(dc.serveHTTP))
+	return dc
+}
+
+// URL returns the base URL of the datacenter.
+func (dc *Datacenter) URL() string {
+	return dc.server.URL
+}
+
+// SetLatency delays every response of the datacenter.
+func (dc *Datacenter) SetLatency(d time.Duration) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.latency = d
+}
+
+// FailWith lets the datacenter answer every request with the given status code, e.g. 503.
+// A status code of 0 restores normal operation.
+func (dc *Datacenter) FailWith(statusCode int) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.status = statusCode
+}
+
+// Hang lets the datacenter never answer, so requests run into the client timeout.
+func (dc *Datacenter) Hang(hang bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.hang = hang
+}
+
+// Requests returns the number of requests the datacenter received.
+func (dc *Datacenter) Requests() int {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.requests
+}
+
+func (dc *Datacenter) hasJob(jobID string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.sms[jobID] != nil || dc.fax[jobID] != nil
+}
+
+func (dc *Datacenter) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	dc.mu.Lock()
+	dc.requests++
+	latency, status, hang := dc.latency, dc.status, dc.hang
+	dc.mu.Unlock()
+
+	if hang {
+		// the body isn't read, so the request context isn't canceled if the client gives up
+		// during the upload, Close ends the request then
+		select {
+		case <-r.Context().Done():
+		case <-dc.srv.closed:
+		}
+		return
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+	if user, password, ok := r.BasicAuth(); !ok || user != dc.srv.User || password != dc.srv.Password {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	// the clients join base addresses and resources in different ways, so double slashes are removed
+	p := path.Clean("/" + r.URL.Path)
+	if !strings.HasPrefix(p, basePath+"/") {
+		writeError(w, http.StatusNotFound, "unknown resource "+p)
+		return
+	}
+	dc.handler(w, r, strings.TrimPrefix(p, basePath))
+}
+
+// handleDatacenter serves the report resources of a datacenter.
+func (dc *Datacenter) handleDatacenter(w http.ResponseWriter, r *http.Request, p string) {
+	faxReports := "/" + dc.srv.CustomerNumber + "/fax/reports"
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/jobs/"):
+		dc.getSMSReport(w, strings.TrimPrefix(p, "/jobs/"))
+	case r.Method == http.MethodGet && p == "/sms":
+		dc.getSMSStatus(w, r.URL.Query().Get("jobId"))
+	case p == faxReports && r.Method == http.MethodGet:
+		dc.getFaxReports(w)
+	case p == faxReports && r.Method == http.MethodDelete:
+		dc.deleteFaxReports(w)
+	case p == faxReports && r.Method == http.MethodPost:
+		dc.bulkFaxReports(w, r)
+	case strings.HasPrefix(p, faxReports+"/") && r.Method == http.MethodGet:
+		dc.getFaxReport(w, strings.TrimPrefix(p, faxReports+"/"))
+	case strings.HasPrefix(p, faxReports+"/") && r.Method == http.MethodDelete:
+		dc.deleteFaxReport(w, strings.TrimPrefix(p, faxReports+"/"))
+	default:
+		writeError(w, http.StatusNotFound, "unknown resource "+p)
+	}
+}
+
+// finished reports whether a job accepted at the given time is finished.
+func (dc *Datacenter) finished(accepted time.Time) bool {
+	return !dc.srv.Now().Before(accepted.Add(dc.srv.options.DeliveryDelay))
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]any{"message": message, "code": statusCode})
+}
+
+// decode reads a JSON request body and returns it, it answers with 400 if the body is invalid.
+func decode(w http.ResponseWriter, r *http.Request, v any) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return nil, false
+	}
+	return body, true
+}
+
+// timestamp formats a time like the Retarus webservices.
+func timestamp(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000-07:00")
+}
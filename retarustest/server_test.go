@@ -0,0 +1,102 @@
+package retarustest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func doRequest(t *testing.T, srv *Server, method string, url string, body string) (*http.Response, map[string]any) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	req.SetBasicAuth(srv.User, srv.Password)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var decoded map[string]any
+	json.NewDecoder(res.Body).Decode(&decoded)
+	return res, decoded
+}
+
+func TestFaxJobLifecycle(t *testing.T) {
+	srv := NewServer(Options{
+		FaxOutcome: func(number string) (string, string) { return "FAILED", "BUSY" },
+	})
+	defer srv.Close()
+	region := srv.FaxRegion()
+
+	res, body := doRequest(t, srv, http.MethodPost, region.HAAddr+srv.CustomerNumber+"/fax",
+		`{"recipients":[{"number":"+4989000000"}],"documents":[{"name":"a.txt","data":"YQ=="}]}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", res.StatusCode)
+	}
+	jobID := body["jobId"].(string)
+	holder := srv.JobDatacenter(jobID)
+
+	for _, server := range region.Servers {
+		res, report := doRequest(t, srv, http.MethodGet, server+srv.CustomerNumber+"/fax/reports/"+jobID, "")
+		if server != holder.URL()+basePath+"/" {
+			if res.StatusCode != http.StatusNotFound {
+				t.Errorf("job should only be visible in its datacenter, got %d", res.StatusCode)
+			}
+			continue
+		}
+		status := report["recipientStatus"].([]any)[0].(map[string]any)["status"]
+		if status != "PENDING" {
+			t.Errorf("job should be pending before the delivery delay, got %v", status)
+		}
+	}
+
+	srv.Advance(10 * time.Second)
+	_, report := doRequest(t, srv, http.MethodGet, holder.URL()+basePath+"/"+srv.CustomerNumber+"/fax/reports/"+jobID, "")
+	recipient := report["recipientStatus"].([]any)[0].(map[string]any)
+	if recipient["status"] != "FAILED" || recipient["reason"] != "BUSY" {
+		t.Errorf("unexpected final status: %v", recipient)
+	}
+
+	if srv.Job(jobID) == nil {
+		t.Error("expected the request body of the job")
+	}
+	doRequest(t, srv, http.MethodDelete, holder.URL()+basePath+"/"+srv.CustomerNumber+"/fax/reports/"+jobID, "")
+	if srv.Job(jobID) != nil || srv.Job("FJunknown") != nil {
+		t.Error("expected no request body for deleted and unknown jobs")
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	dc := srv.Datacenters[0]
+
+	dc.FailWith(http.StatusServiceUnavailable)
+	res, _ := doRequest(t, srv, http.MethodGet, dc.URL()+basePath+"/jobs/J1", "")
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected injected 503, got %d", res.StatusCode)
+	}
+
+	dc.FailWith(0)
+	req, _ := http.NewRequest(http.MethodGet, dc.URL()+basePath+"/jobs/J1", nil)
+	req.SetBasicAuth(srv.User, "wrong")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for wrong credentials, got %d", res.StatusCode)
+	}
+
+	dc.Hang(true)
+	client := http.Client{Timeout: 50 * time.Millisecond}
+	req, _ = http.NewRequest(http.MethodGet, dc.URL()+basePath+"/jobs/J1", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Errorf("hanging datacenter should run into the client timeout")
+	}
+	if dc.Requests() != 3 {
+		t.Errorf("expected 3 requests, got %d", dc.Requests())
+	}
+}
@@ -0,0 +1,173 @@
+package retarustest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// smsJobRequest is the part of an SMS job request the fake evaluates.
+type smsJobRequest struct {
+	Messages []struct {
+		Text       string `json:"text"`
+		Recipients []struct {
+			Dst         string `json:"dst"`
+			CustomerRef string `json:"customerRef"`
+		} `json:"recipients"`
+	} `json:"messages"`
+	Options *struct {
+		Src             string `json:"src"`
+		Encoding        string `json:"encoding"`
+		Billcode        string `json:"billcode"`
+		StatusRequested bool   `json:"statusRequested"`
+		Flash           bool   `json:"flash"`
+		CustomerRef     string `json:"customerRef"`
+		ValidityMin     int    `json:"validityMin"`
+		QOS             string `json:"qos"`
+	} `json:"options"`
+}
+
+type smsJob struct {
+	id       string
+	body     []byte
+	request  smsJobRequest
+	accepted time.Time
+	sms      []smsMessage
+}
+
+type smsMessage struct {
+	id          string
+	dst         string
+	customerRef string
+}
+
+func (dc *Datacenter) sendSMS(w http.ResponseWriter, r *http.Request) {
+	var req smsJobRequest
+	body, ok := decode(w, r, &req)
+	if !ok {
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one message is required")
+		return
+	}
+
+	job := &smsJob{
+		id:       dc.srv.newJobID("J"),
+		body:     body,
+		request:  req,
+		accepted: dc.srv.Now(),
+	}
+	for _, m := range req.Messages {
+		if len(m.Recipients) == 0 {
+			writeError(w, http.StatusBadRequest, "at least one recipient is required per message")
+			return
+		}
+		for _, rcpt := range m.Recipients {
+			if rcpt.Dst == "" {
+				writeError(w, http.StatusBadRequest, "recipient without dst")
+				return
+			}
+			ref := rcpt.CustomerRef
+			if ref == "" {
+				ref = rcpt.Dst
+			}
+			job.sms = append(job.sms, smsMessage{
+				id:          fmt.Sprintf("%s-%d", job.id, len(job.sms)+1),
+				dst:         rcpt.Dst,
+				customerRef: ref,
+			})
+		}
+	}
+
+	dc.mu.Lock()
+	dc.sms[job.id] = job
+	dc.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]string{"jobId": job.id})
+}
+
+func (dc *Datacenter) smsJob(jobID string) *smsJob {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.sms[jobID]
+}
+
+func (dc *Datacenter) getSMSReport(w http.ResponseWriter, jobID string) {
+	job := dc.smsJob(jobID)
+	if job == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	report := map[string]any{
+		"jobId":           job.id,
+		"src":             "",
+		"encoding":        "STANDARD",
+		"billcode":        "",
+		"statusRequested": false,
+		"flash":           false,
+		"validityMin":     0,
+		"customerRef":     "",
+		"qos":             "NORMAL",
+		"receiptTs":       timestamp(job.accepted),
+	}
+	if o := job.request.Options; o != nil {
+		report["src"] = o.Src
+		report["billcode"] = o.Billcode
+		report["statusRequested"] = o.StatusRequested
+		report["flash"] = o.Flash
+		report["validityMin"] = o.ValidityMin
+		report["customerRef"] = o.CustomerRef
+		if o.Encoding != "" {
+			report["encoding"] = o.Encoding
+		}
+		if o.QOS != "" {
+			report["qos"] = o.QOS
+		}
+	}
+	if dc.finished(job.accepted) {
+		report["finishedTs"] = timestamp(job.accepted.Add(dc.srv.options.DeliveryDelay))
+	}
+	ids := []string{}
+	for _, m := range job.sms {
+		ids = append(ids, m.id)
+	}
+	report["recipientIds"] = ids
+	writeJSON(w, http.StatusOK, report)
+}
+
+// getSMSStatus reports the status of every SMS of a job. A SMS is queued during the first half
+// of the delivery delay, dispatched during the second half and delivered afterwards.
+func (dc *Datacenter) getSMSStatus(w http.ResponseWriter, jobID string) {
+	job := dc.smsJob(jobID)
+	if job == nil {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	delay := dc.srv.options.DeliveryDelay
+	elapsed := dc.srv.Now().Sub(job.accepted)
+	statuses := []map[string]any{}
+	for _, m := range job.sms {
+		status := map[string]any{
+			"smsId":         m.id,
+			"dst":           m.dst,
+			"customerRef":   m.customerRef,
+			"processStatus": "QUEUED",
+			"status":        "WAITING",
+			"reason":        "",
+		}
+		if elapsed >= delay/2 {
+			status["processStatus"] = "DISPATCHED"
+			status["sentTs"] = timestamp(job.accepted.Add(delay / 2))
+		}
+		if elapsed >= delay {
+			status["processStatus"] = "FINISHED"
+			status["status"] = "OK"
+			status["reason"] = "DELIVERED"
+			status["finishedTs"] = timestamp(job.accepted.Add(delay))
+		}
+		statuses = append(statuses, status)
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
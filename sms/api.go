@@ -0,0 +1,23 @@
+package sms
+
+import "context"
+
+// Sender is implemented by everything which can send SMS jobs.
+type Sender interface {
+	Send(job Job) (jobID string, err error)
+	SendContext(ctx context.Context, job Job) (jobID string, err error)
+}
+
+// API holds the basic send and report methods of *Client. Code which depends on API instead of
+// *Client can be tested with a fake like smstest.Recorder, and the client can be wrapped in
+// decorators, e.g. for auditing or caching, which implement API themselves. SendWithOptions is
+// only available on *Client.
+type API interface {
+	Sender
+	GetReport(jobID string) (*Report, error)
+	GetReportContext(ctx context.Context, jobID string) (*Report, error)
+	GetSmsStatus(jobID string) (*[]SmsStatus, error)
+	GetSmsStatusContext(ctx context.Context, jobID string) (*[]SmsStatus, error)
+}
+
+var _ API = (*Client)(nil)
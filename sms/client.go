@@ -2,11 +2,16 @@ package sms
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/retarus/retarus-go/common"
 	"net/http"
 	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client is responsible for sending requests and handling transportation for an SMS service.
@@ -18,6 +23,13 @@ type Client struct {
 
 	// Transporter is responsible for the actual HTTP requests and responses.
 	Transporter common.Transporter
+
+	// Suppression (optional) is looked up for every recipient before a job is sent, suppressed
+	// recipients are removed from the job.
+	Suppression common.Suppression
+
+	// Idempotency (optional) stores the job IDs of sends with SendOptions.IdempotencyKey.
+	Idempotency common.IdempotencyStore
 }
 
 // NewClient creates and returns a new Client instance.
@@ -25,6 +37,8 @@ type Client struct {
 // The Transporter is initialized with a default timeout of 5 seconds.
 //
 // This is the preferred way to create a new Client instance.
+// The methods of Client have pointer receivers, so *Client implements the API and Sender
+// interfaces; depend on those interfaces to substitute the client in tests.
 //
 // Parameters:
 //   - config: A Config object containing settings like API keys, base URLs, etc.
@@ -40,32 +54,93 @@ func NewClient(config Config) Client {
 
 // Send sends a sms job to the specified numbers in the job.
 func (c *Client) Send(job Job) (jobID string, err error) {
+	return c.SendContext(context.Background(), job)
+}
+
+// SendContext is like Send, but waits for the client side send limiter under the control of ctx.
+func (c *Client) SendContext(ctx context.Context, job Job) (jobID string, err error) {
+	result, err := c.SendWithOptions(ctx, job, SendOptions{})
+	return result.JobID, err
+}
+
+// SendWithOptions is like SendContext, and returns the recipients which were removed from the job
+// because they are suppressed. If every recipient is suppressed, the job isn't sent and the error
+// is common.ErrAllSuppressed.
+//
+// If opts has an IdempotencyKey which the Idempotency store of the client already has a job ID for,
+// the job isn't sent again and the result has this job ID with Replayed set. A concurrent send with
+// the same key fails with common.ErrIdempotencyKeyInUse.
+// The key isn't sent to the service. Options.DuplicateDetection additionally lets the service
+// reject the same messages to the same recipients within 10 minutes with ErrDuplicateJob, even if
+// they were sent with another key.
+func (c *Client) SendWithOptions(ctx context.Context, job Job, opts SendOptions) (result SendResult, err error) {
+	ctx, span := c.startSpan(ctx, "Send", common.RecipientCountKey.Int(job.recipientCount()))
+	defer func() { common.EndSpan(span, err) }()
+
+	// rejected is cleared once the request is sent, unless the service rejects the job
+	rejected := true
+	if key := opts.IdempotencyKey; key != "" {
+		if c.Idempotency == nil {
+			return result, common.ErrNoIdempotencyStore
+		}
+		jobID, err := c.Idempotency.Claim(ctx, key)
+		if err != nil {
+			return result, err
+		}
+		if jobID != "" {
+			span.SetAttributes(common.ReplayedKey.Bool(true), common.JobIDKey.String(jobID))
+			return SendResult{JobID: jobID, Replayed: true}, nil
+		}
+		defer func() { err = common.SettleIdempotencyKey(ctx, c.Idempotency, key, result.JobID, err, rejected) }()
+	}
+
+	job, result.Suppressed, err = suppress(ctx, c.Suppression, job)
+	if err != nil {
+		return result, err
+	}
+	if len(result.Suppressed) > 0 {
+		span.SetAttributes(common.SuppressedCountKey.Int(len(result.Suppressed)))
+		if opts.RejectSuppressed {
+			return result, fmt.Errorf("%w: %d recipients", common.ErrSuppressed, len(result.Suppressed))
+		}
+		if job.recipientCount() == 0 {
+			return result, common.ErrAllSuppressed
+		}
+	}
+	result.JobID, rejected, err = c.send(ctx, span, job)
+	return result, err
+}
+
+// send posts the job, the span of the caller gets the HTTP status and the job ID. rejected is set
+// if the job certainly wasn't created.
+func (c *Client) send(ctx context.Context, span trace.Span, job Job) (jobID string, rejected bool, err error) {
 	jobBytes, err := json.Marshal(job)
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
 
 	u, err := url.JoinPath(c.Config.Region.HAAddr, "/jobs")
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
-	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(jobBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(jobBytes))
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	req.SetBasicAuth(c.Config.User, c.Config.Password)
 
-	resp, err := c.Transporter.HTTPClient.Do(req)
+	resp, err := c.Transporter.Do(ctx, common.Call{Service: "sms", Operation: "Send", Class: common.SendOperation}, req)
 
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(common.HTTPStatusKey.Int(resp.StatusCode))
 
 	if err := statusToError(resp.StatusCode, resp.Body); err != nil {
-		return "", err
+		return "", isRejection(resp.StatusCode), err
 	}
 
 	type jobResp struct {
@@ -74,10 +149,14 @@ func (c *Client) Send(job Job) (jobID string, err error) {
 
 	var jobResponse jobResp
 	if err := json.NewDecoder(resp.Body).Decode(&jobResponse); err != nil {
-		return "", err
+		return "", false, err
 	}
 
-	return jobResponse.JobID, nil
+	span.SetAttributes(common.JobIDKey.String(jobResponse.JobID))
+	if c.Transporter.Metrics != nil {
+		c.Transporter.Metrics.AddSMSParts(job.parts())
+	}
+	return jobResponse.JobID, false, nil
 }
 
 // GetReport retrieves the status and list of SMS IDs for a specific job by its job ID.
@@ -92,9 +171,20 @@ func (c *Client) Send(job Job) (jobID string, err error) {
 //   - A pointer to a Report object containing details about the job's SMS statuses and IDs.
 //   - An error object if an error occurs during the fetch operation or if no report is found.
 func (c *Client) GetReport(jobID string) (*Report, error) {
+	return c.GetReportContext(context.Background(), jobID)
+}
+
+// GetReportContext is like GetReport, but waits for the client side report limiter under the control of ctx.
+func (c *Client) GetReportContext(ctx context.Context, jobID string) (report *Report, err error) {
+	ctx, span := c.startSpan(ctx, "GetReport", common.JobIDKey.String(jobID))
+	defer func() { common.EndSpan(span, err) }()
+
 	var smsReport Report
 
-	resp := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, []byte{}, "/jobs/"+jobID, http.MethodGet)
+	resp, err := c.fetch(ctx, "GetReport", http.MethodGet, "/jobs/"+jobID)
+	if err != nil {
+		return nil, err
+	}
 	if len(resp) == 0 {
 		return nil, errors.New("Error occured during fetch of responses.")
 	}
@@ -117,6 +207,9 @@ func (c *Client) GetReport(jobID string) (*Report, error) {
 	if smsReport.IsZero() == true {
 		return nil, errors.New("no reports found, try again later or contact customer service")
 	}
+	if smsReport.FinishedTS != nil {
+		c.Transporter.ObservePollingLag("sms", smsReport.FinishedTS.Time)
+	}
 	return &smsReport, nil
 }
 
@@ -132,10 +225,21 @@ func (c *Client) GetReport(jobID string) (*Report, error) {
 //   - A pointer to an SmsStatus object containing details about the individual SMS statuses within the job.
 //   - An error object if an error occurs during the fetch operation or if no statuses are found.
 func (c *Client) GetSmsStatus(jobID string) (*[]SmsStatus, error) {
+	return c.GetSmsStatusContext(context.Background(), jobID)
+}
+
+// GetSmsStatusContext is like GetSmsStatus, but waits for the client side report limiter under the control of ctx.
+func (c *Client) GetSmsStatusContext(ctx context.Context, jobID string) (statuses *[]SmsStatus, err error) {
+	ctx, span := c.startSpan(ctx, "GetSmsStatus", common.JobIDKey.String(jobID))
+	defer func() { common.EndSpan(span, err) }()
+
 	var status []SmsStatus
 
 	parms := common.KvParams{Key: "jobId", Value: jobID}
-	resp := c.Transporter.DoDatacenterFetch(c.Config.Region.Servers, c.Config.User, c.Config.Password, []byte{}, "/sms", http.MethodGet, parms)
+	resp, err := c.fetch(ctx, "GetSmsStatus", http.MethodGet, "/sms", parms)
+	if err != nil {
+		return nil, err
+	}
 	for x := range resp {
 		if resp[x].StatusCode == 404 {
 			continue
@@ -155,3 +259,22 @@ func (c *Client) GetSmsStatus(jobID string) (*[]SmsStatus, error) {
 	}
 	return &status, nil
 }
+
+// startSpan starts the span of a client call.
+func (c *Client) startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.Transporter.StartSpan(ctx, "sms", operation, c.Config.Region.Region, attrs...)
+}
+
+// fetch sends a report query to all datacenters of the configured region.
+func (c *Client) fetch(ctx context.Context, operation string, method string, resource string, params ...common.KvParams) ([]*http.Response, error) {
+	return c.Transporter.DoDatacenterFetchContext(ctx, common.DatacenterRequest{
+		Call:     common.Call{Service: "sms", Operation: operation, Class: common.ReportOperation},
+		Servers:  c.Config.Region.Servers,
+		Username: c.Config.User,
+		Password: c.Config.Password,
+		Body:     []byte{},
+		Resource: resource,
+		Method:   method,
+		Params:   params,
+	})
+}
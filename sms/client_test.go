@@ -1,17 +1,19 @@
 package sms
 
 import (
-	"github.com/retarus/retarus-go/common"
+	"github.com/retarus/retarus-go/retarustest"
 	"testing"
 	"time"
 )
 
-func smsClientProvider() Client {
-	config := NewConfigFromEnv(common.Europe)
-	return NewClient(config)
+func smsClientProvider(t *testing.T) (Client, *retarustest.Server) {
+	srv := retarustest.NewServer(retarustest.Options{DeliveryDelay: 8 * time.Second})
+	t.Cleanup(srv.Close)
+	config := Config{User: srv.User, Password: srv.Password, Region: srv.SMSRegion()}
+	return NewClient(config), srv
 }
 
-func generateSms(amount int) []string {
+func generateSms(client Client, amount int) []string {
 	job := Job{
 		Messages: []Message{
 			{
@@ -26,7 +28,6 @@ func generateSms(amount int) []string {
 		},
 	}
 	jobIds := []string{}
-	client := smsClientProvider()
 	for i := 1; i < amount+1; i++ {
 		res, _ := client.Send(job)
 		jobIds = append(jobIds, res)
@@ -48,7 +49,7 @@ func TestNormalSend(t *testing.T) {
 			},
 		},
 	}
-	client := smsClientProvider()
+	client, _ := smsClientProvider(t)
 	_, err := client.Send(job)
 	if err != nil {
 		t.Errorf("Error shouldn't happen here: %s", err)
@@ -56,9 +57,9 @@ func TestNormalSend(t *testing.T) {
 }
 
 func TestGetReport(t *testing.T) {
-	jobId := generateSms(1)
-	client := smsClientProvider()
-	time.Sleep(time.Duration(8 * time.Second))
+	client, srv := smsClientProvider(t)
+	jobId := generateSms(client, 1)
+	srv.Advance(8 * time.Second)
 	res, err := client.GetReport(jobId[0])
 	if err != nil {
 		t.Errorf("Error shouldn't happen here: %s", err)
@@ -69,11 +70,31 @@ func TestGetReport(t *testing.T) {
 	if res.JobID != jobId[0] {
 		t.Errorf("Returned Job Id isn't matching with requested one.")
 	}
+	if res.FinishedTS == nil {
+		t.Errorf("Job should be finished after the delivery delay.")
+	}
+}
+
+func TestGetReportFromSecondDatacenter(t *testing.T) {
+	client, srv := smsClientProvider(t)
+	jobIds := generateSms(client, 2)
+	for _, jobId := range jobIds {
+		res, err := client.GetReport(jobId)
+		if err != nil {
+			t.Fatalf("Error shouldn't happen here: %s", err)
+		}
+		if res.JobID != jobId {
+			t.Errorf("Returned Job Id isn't matching with requested one.")
+		}
+	}
+	if srv.JobDatacenter(jobIds[0]) == srv.JobDatacenter(jobIds[1]) {
+		t.Errorf("Jobs should be spread over both datacenters")
+	}
 }
 
 func TestGetUnknownReport(t *testing.T) {
 	unkownJobId := "123412341234124"
-	client := smsClientProvider()
+	client, _ := smsClientProvider(t)
 	_, err := client.GetReport(unkownJobId)
 	if err == nil {
 		t.Errorf("Error should happen here")
@@ -82,11 +103,14 @@ func TestGetUnknownReport(t *testing.T) {
 }
 
 func TestGetSmsStatus(t *testing.T) {
-	jobId := generateSms(1)
-	client := smsClientProvider()
-	time.Sleep(time.Duration(8 * time.Second))
-	_, err := client.GetSmsStatus(jobId[0])
-	if err == nil {
-		t.Errorf("Error should happen here")
+	client, srv := smsClientProvider(t)
+	jobId := generateSms(client, 1)
+	srv.Advance(8 * time.Second)
+	res, err := client.GetSmsStatus(jobId[0])
+	if err != nil {
+		t.Errorf("Error shouldn't happen here: %s", err)
+	}
+	if res == nil || len(*res) != 1 {
+		t.Errorf("Expected the status of one sms")
 	}
 }
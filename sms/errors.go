@@ -2,11 +2,16 @@ package sms
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 )
 
+// ErrDuplicateJob is returned if the service rejected a job with Options.DuplicateDetection
+// because the same job was sent within the last 10 minutes.
+var ErrDuplicateJob = errors.New("duplicate job")
+
 func statusToError(statusCode int, body io.Reader) error {
 	switch statusCode {
 	case http.StatusCreated, http.StatusOK:
@@ -25,8 +30,17 @@ func statusToError(statusCode int, body io.Reader) error {
 
 	var errF errFormat
 	if err := json.Unmarshal(msg, &errF); err == nil {
-		return fmt.Errorf("%s: %s", http.StatusText(statusCode), errF.Message)
+		msg = []byte(errF.Message)
+	}
+	if statusCode == http.StatusConflict {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, msg)
 	}
 
 	return fmt.Errorf("%s: %s", http.StatusText(statusCode), string(msg))
 }
+
+// isRejection reports whether a status code of a send means that the job wasn't created. A 409
+// rejects a duplicate of a job which was created before.
+func isRejection(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 && statusCode != http.StatusConflict
+}
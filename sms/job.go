@@ -1,9 +1,6 @@
 package sms
 
-import (
-	"fmt"
-	"time"
-)
+import "github.com/retarus/retarus-go/common"
 
 // Job is a SMS Job Request specified in 4.2.
 type Job struct {
@@ -33,6 +30,15 @@ func (j *Job) AddMessages(m []Message) {
 	j.Messages = m
 }
 
+// recipientCount returns the number of recipients over all messages of the job.
+func (j *Job) recipientCount() int {
+	count := 0
+	for _, m := range j.Messages {
+		count += len(m.Recipients)
+	}
+	return count
+}
+
 type Recipient struct {
 	// Dst (required) Recipient’s mobile phone number. If a number is specified
 	// without a country code, the value specified in the Country
@@ -56,10 +62,13 @@ type Recipient struct {
 	// • If blackout periods are specified at the Recipient level,
 	// only they are used. The blackout periods in the Options
 	// are then ignored.
-	BlackoutPeriods []time.Time `json:"blackoutPeriods,omitempty"`
+	// Examples:
+	// • 2018-10-25T18:00Z/2018-10-26T07:00Z
+	// • 2018-10-25T18:00+01:00/2018-10-26T07:00+01:00
+	BlackoutPeriods []string `json:"blackoutPeriods,omitempty"`
 }
 
-func NewRecipient(destination string, customerRef string, blackout []time.Time) Recipient {
+func NewRecipient(destination string, customerRef string, blackout []string) Recipient {
 	return Recipient{
 		destination,
 		customerRef,
@@ -197,7 +206,7 @@ type Options struct {
 	// JobPeriod (optional) Timestamp setting the transmission time of the SMS Job.
 	// In accordance with the ISO-8601 standard e.g., Z can be
 	// +02:00.
-	JobPeriod *ISO8601Time `json:"jobPeriod,omitempty"`
+	JobPeriod *common.ISO8601Time `json:"jobPeriod,omitempty"`
 	// DuplicateDetection (optional)
 	// • If enabled, equal requests are rejected with a 409
 	// (Conflict) status code
@@ -227,12 +236,6 @@ type Options struct {
 	BlackoutPeriods []string `json:"blackoutPeriods,omitempty"`
 }
 
-type ISO8601Time time.Time
-
-func (t ISO8601Time) MarshalJSON() ([]byte, error) {
-	stamp := fmt.Sprintf("\"%s\"", time.Time(t).Format(layout))
-
-	return []byte(stamp), nil
-}
-
-const layout = "2006-01-02T15:04:05.999-0700"
+// ISO8601Time is the timestamp type of the jobs and reports, an alias of common.ISO8601Time
+// which keeps code written against earlier versions of this package compiling.
+type ISO8601Time = common.ISO8601Time
@@ -0,0 +1,37 @@
+package sms
+
+import (
+	"testing"
+
+	"github.com/retarus/retarus-go/internal/jsontest"
+)
+
+func TestJobRoundTrip(t *testing.T) {
+	var job Job
+	jsontest.AssertRoundTrip(t, "job.json", &job)
+	if _, offset := job.Options.JobPeriod.Zone(); offset != 2*60*60 {
+		t.Errorf("the offset of the job period got lost: %v", job.Options.JobPeriod)
+	}
+}
+
+func TestReportRoundTrip(t *testing.T) {
+	var report Report
+	jsontest.AssertRoundTrip(t, "report.json", &report)
+	if report.FinishedTS == nil {
+		t.Error("expected the finished timestamp")
+	}
+
+	var pending Report
+	jsontest.AssertRoundTrip(t, "pending_report.json", &pending)
+	if pending.FinishedTS != nil {
+		t.Errorf("a pending report isn't finished, got %v", pending.FinishedTS)
+	}
+}
+
+func TestSmsStatusRoundTrip(t *testing.T) {
+	var statuses []SmsStatus
+	jsontest.AssertRoundTrip(t, "sms_status.json", &statuses)
+	if statuses[0].SmsID != "S8D8DUQZ6MN2JMXE2TEI4" || statuses[1].SentTS != nil || statuses[1].IsZero() {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+}
@@ -0,0 +1,75 @@
+package sms
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// gsm7Basic contains the characters of the GSM 03.38 default alphabet, each takes one septet.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension contains the characters of the GSM 03.38 extension table, each takes two septets.
+const gsm7Extension = "^{}\\[~]|€\f"
+
+// Parts returns the number of SMS parts the text is split into with the given encoding.
+// A text which can't be represented in the GSM 7-bit alphabet is counted as UTF-16, just like
+// a text with the UTF16 encoding: 70 characters for a single part and 67 per part of a
+// concatenated SMS. GSM 7-bit texts take 160 respectively 153 characters per part, characters
+// like € count twice.
+func Parts(text string, encoding Encoding) int {
+	if text == "" {
+		return 1
+	}
+	if septets, ok := gsm7Length(text); ok && encoding != UTF16 {
+		return partsOf(septets, 160, 153)
+	}
+	return partsOf(len(utf16.Encode([]rune(text))), 70, 67)
+}
+
+// IsGSM7 reports whether the text can be sent with the STANDARD encoding without replacing characters.
+func IsGSM7(text string) bool {
+	_, ok := gsm7Length(text)
+	return ok
+}
+
+func gsm7Length(text string) (int, bool) {
+	length := 0
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			length++
+		case strings.ContainsRune(gsm7Extension, r):
+			length += 2
+		default:
+			return 0, false
+		}
+	}
+	return length, true
+}
+
+func partsOf(length int, single int, concatenated int) int {
+	if length <= single {
+		return 1
+	}
+	return (length + concatenated - 1) / concatenated
+}
+
+// parts returns the number of SMS parts of the job over all messages and recipients.
+func (j *Job) parts() int {
+	encoding := STANDARD
+	maxParts := 0
+	if j.Options != nil {
+		encoding = j.Options.Encoding
+		maxParts = j.Options.MaxParts
+	}
+	total := 0
+	for _, m := range j.Messages {
+		p := Parts(m.Text, encoding)
+		if maxParts > 0 && p > maxParts {
+			p = maxParts
+		}
+		total += p * len(m.Recipients)
+	}
+	return total
+}
@@ -0,0 +1,29 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParts(t *testing.T) {
+	tests := []struct {
+		text     string
+		encoding Encoding
+		parts    int
+	}{
+		{"hello", STANDARD, 1},
+		{strings.Repeat("a", 160), STANDARD, 1},
+		{strings.Repeat("a", 161), STANDARD, 2},
+		{strings.Repeat("€", 80), STANDARD, 1},
+		{strings.Repeat("€", 81), STANDARD, 2},
+		{strings.Repeat("a", 70), UTF16, 1},
+		{strings.Repeat("a", 71), UTF16, 2},
+		{"Grüße aus München 😀", STANDARD, 1},
+		{strings.Repeat("ł", 135), STANDARD, 3},
+	}
+	for _, tt := range tests {
+		if p := Parts(tt.text, tt.encoding); p != tt.parts {
+			t.Errorf("Parts(%q, %s) = %d, expected %d", tt.text, tt.encoding, p, tt.parts)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package sms
 
 import (
-	"time"
+	"github.com/retarus/retarus-go/common"
 )
 
 // Report is a JobReport specified in 4.1
@@ -25,9 +25,9 @@ type Report struct {
 	// QOS (required)
 	QOS string `json:"qos"`
 	// ReceiptTS (required)
-	ReceiptTS time.Time `json:"receiptTs"`
+	ReceiptTS common.ISO8601Time `json:"receiptTs"`
 	// FinishedTs (optional)
-	FinishedTS time.Time `json:"finishedTs,omitempty"`
+	FinishedTS *common.ISO8601Time `json:"finishedTs,omitempty"`
 	// RecipientIDs (required)
 	RecipientIDs []string `json:"recipientIds"`
 }
@@ -42,7 +42,7 @@ func (r Report) IsZero() bool {
 		r.ValidityMin == 0 &&
 		r.CustomerRef == "" &&
 		r.QOS == "" &&
-		r.ReceiptTS.IsZero() && // Für time.Time verwenden wir die Methode IsZero()
-		r.FinishedTS.IsZero() && // Für time.Time verwenden wir die Methode IsZero()
+		r.ReceiptTS.IsZero() &&
+		r.FinishedTS == nil &&
 		len(r.RecipientIDs) == 0 // Für slices überprüfen wir die Länge
 }
@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"context"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+// SendOptions configure Client.SendWithOptions.
+type SendOptions struct {
+	// RejectSuppressed (optional) fails the send with common.ErrSuppressed if any recipient is
+	// suppressed, instead of sending the job to the remaining recipients.
+	RejectSuppressed bool
+	// IdempotencyKey (optional) identifies the send, e.g. by the ID of a queued message, so a
+	// retried send with the same key returns the job ID of the first one. It requires
+	// Client.Idempotency.
+	IdempotencyKey string
+}
+
+// SendResult is the result of Client.SendWithOptions.
+type SendResult struct {
+	// JobID is the ID of the sent job, empty if it wasn't sent.
+	JobID string
+	// Replayed is true if the job was already sent with the same IdempotencyKey and wasn't sent
+	// again, Suppressed is empty then.
+	Replayed bool
+	// Suppressed are the recipients which were removed from the job, in the order of the job.
+	Suppressed []SuppressedRecipient
+}
+
+// SuppressedRecipient is a recipient which was removed from a job with its suppression entry.
+type SuppressedRecipient struct {
+	// Message is the index of the recipient's message in the job.
+	Message   int
+	Recipient Recipient
+	Entry     common.SuppressionEntry
+}
+
+// suppress returns the job without the suppressed recipients and without messages which have no
+// recipients left. The messages of the given job aren't modified.
+func suppress(ctx context.Context, s common.Suppression, job Job) (Job, []SuppressedRecipient, error) {
+	if s == nil {
+		return job, nil, nil
+	}
+	type lookup struct {
+		entry common.SuppressionEntry
+		ok    bool
+	}
+	seen := map[string]lookup{}
+	var suppressed []SuppressedRecipient
+	messages := make([]Message, 0, len(job.Messages))
+	for i, m := range job.Messages {
+		recipients := make([]Recipient, 0, len(m.Recipients))
+		for _, r := range m.Recipients {
+			l, done := seen[r.Dst]
+			if !done {
+				entry, ok, err := s.Lookup(ctx, r.Dst)
+				if err != nil {
+					return job, nil, err
+				}
+				l = lookup{entry, ok}
+				seen[r.Dst] = l
+			}
+			if l.ok {
+				suppressed = append(suppressed, SuppressedRecipient{Message: i, Recipient: r, Entry: l.entry})
+				continue
+			}
+			recipients = append(recipients, r)
+		}
+		if len(recipients) > 0 {
+			messages = append(messages, NewMessage(m.Text, recipients))
+		}
+	}
+	if len(suppressed) == 0 {
+		return job, nil, nil
+	}
+	return NewJob(messages, job.Options), suppressed, nil
+}
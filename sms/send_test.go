@@ -0,0 +1,150 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/retarus/retarus-go/common"
+)
+
+func suppressionJob() Job {
+	return NewJob([]Message{
+		NewMessage("first", []Recipient{
+			NewRecipient("+49176000001", "a", nil),
+			NewRecipient("+49176000002", "b", nil),
+		}),
+		NewMessage("second", []Recipient{
+			NewRecipient("+49 176 000002", "c", nil),
+		}),
+		NewMessage("third", []Recipient{
+			NewRecipient("+49176000003", "d", nil),
+		}),
+	}, nil)
+}
+
+func TestSendRemovesSuppressedRecipients(t *testing.T) {
+	client, srv := smsClientProvider(t)
+	client.Suppression = common.NewMemorySuppression(common.SuppressionEntry{Number: "+49176000002", Reason: common.OptOut})
+	job := suppressionJob()
+
+	result, err := client.SendWithOptions(context.Background(), job, SendOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Suppressed) != 2 || result.Suppressed[0].Recipient.CustomerRef != "b" || result.Suppressed[1].Message != 1 || result.Suppressed[1].Entry.Reason != common.OptOut {
+		t.Fatalf("unexpected suppressed recipients %+v", result.Suppressed)
+	}
+
+	var sent Job
+	if err := json.Unmarshal(srv.Job(result.JobID), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Messages) != 2 || sent.Messages[0].Text != "first" || len(sent.Messages[0].Recipients) != 1 || sent.Messages[1].Text != "third" {
+		t.Errorf("unexpected sent job %+v", sent)
+	}
+	if len(job.Messages[0].Recipients) != 2 {
+		t.Error("the job of the caller must not be modified")
+	}
+}
+
+func TestSendRejectsSuppressedRecipients(t *testing.T) {
+	client, _ := smsClientProvider(t)
+	client.Suppression = common.NewMemorySuppression(common.SuppressionEntry{Number: "+49176000003", Reason: common.HardBounce})
+
+	result, err := client.SendWithOptions(context.Background(), suppressionJob(), SendOptions{RejectSuppressed: true})
+	if !errors.Is(err, common.ErrSuppressed) {
+		t.Fatalf("expected ErrSuppressed, got %v", err)
+	}
+	if result.JobID != "" || len(result.Suppressed) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestSendAllSuppressed(t *testing.T) {
+	client, _ := smsClientProvider(t)
+	client.Suppression = common.NewMemorySuppression(
+		common.SuppressionEntry{Number: "+49176000001"},
+		common.SuppressionEntry{Number: "+49176000002"},
+		common.SuppressionEntry{Number: "+49176000003"},
+	)
+	if _, err := client.Send(suppressionJob()); !errors.Is(err, common.ErrAllSuppressed) {
+		t.Fatalf("expected ErrAllSuppressed, got %v", err)
+	}
+}
+
+func TestSendWithIdempotencyKey(t *testing.T) {
+	client, srv := smsClientProvider(t)
+	ctx := context.Background()
+	job := suppressionJob()
+	if _, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "msg-1"}); !errors.Is(err, common.ErrNoIdempotencyStore) {
+		t.Fatalf("expected ErrNoIdempotencyStore, got %v", err)
+	}
+
+	client.Idempotency = common.NewMemoryIdempotencyStore()
+	first, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "msg-1"})
+	if err != nil || first.Replayed {
+		t.Fatalf("unexpected first send %+v, %v", first, err)
+	}
+	retry, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "msg-1"})
+	if err != nil || !retry.Replayed || retry.JobID != first.JobID {
+		t.Fatalf("expected the retry to return job %s, got %+v, %v", first.JobID, retry, err)
+	}
+	other, err := client.SendWithOptions(ctx, job, SendOptions{IdempotencyKey: "msg-2"})
+	if err != nil || other.JobID == first.JobID {
+		t.Fatalf("expected a new job for another key, got %+v, %v", other, err)
+	}
+
+	var sent Job
+	if err := json.Unmarshal(srv.Job(first.JobID), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Options != nil && sent.Options.DuplicateDetection {
+		t.Error("duplicate detection must only be enabled by the job")
+	}
+}
+
+func TestSendReleasesIdempotencyKeyOnFailure(t *testing.T) {
+	client, _ := smsClientProvider(t)
+	client.Idempotency = common.NewMemoryIdempotencyStore()
+	client.Suppression = common.NewMemorySuppression(common.SuppressionEntry{Number: "+49176000003"})
+	ctx := context.Background()
+
+	if _, err := client.SendWithOptions(ctx, suppressionJob(), SendOptions{IdempotencyKey: "msg-1", RejectSuppressed: true}); !errors.Is(err, common.ErrSuppressed) {
+		t.Fatalf("expected ErrSuppressed, got %v", err)
+	}
+	result, err := client.SendWithOptions(ctx, suppressionJob(), SendOptions{IdempotencyKey: "msg-1"})
+	if err != nil || result.Replayed || result.JobID == "" {
+		t.Fatalf("expected the retry to send the job, got %+v, %v", result, err)
+	}
+}
+
+func TestSendKeepsIdempotencyKeyOnUnknownOutcome(t *testing.T) {
+	client, srv := smsClientProvider(t)
+	client.Idempotency = common.NewMemoryIdempotencyStore()
+	ctx := context.Background()
+
+	srv.HA.FailWith(http.StatusBadRequest)
+	if _, err := client.SendWithOptions(ctx, suppressionJob(), SendOptions{IdempotencyKey: "msg-1"}); err == nil {
+		t.Fatal("expected the rejected send to fail")
+	}
+	srv.HA.FailWith(http.StatusServiceUnavailable)
+	if _, err := client.SendWithOptions(ctx, suppressionJob(), SendOptions{IdempotencyKey: "msg-1"}); err == nil || errors.Is(err, common.ErrIdempotencyKeyInUse) {
+		t.Fatalf("expected the rejected send to release the key, got %v", err)
+	}
+	srv.HA.FailWith(0)
+	// the job may have been created by the failed send, so the key stays claimed
+	if _, err := client.SendWithOptions(ctx, suppressionJob(), SendOptions{IdempotencyKey: "msg-1"}); !errors.Is(err, common.ErrIdempotencyKeyInUse) {
+		t.Fatalf("expected ErrIdempotencyKeyInUse, got %v", err)
+	}
+}
+
+func TestDuplicateJobStatus(t *testing.T) {
+	err := statusToError(http.StatusConflict, strings.NewReader(`{"message":"duplicate request","code":409}`))
+	if !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("expected ErrDuplicateJob, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package smstest provides a fake implementation of sms.API for tests.
+package smstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/retarus/retarus-go/sms"
+)
+
+// ErrNotFound is returned for job IDs without a prepared report or status.
+var ErrNotFound = errors.New("no reports found")
+
+// Call is a single recorded call of a Recorder.
+type Call struct {
+	// Method is the name of the called method, the Context variants are recorded under the plain name.
+	Method string
+	// JobID is the job ID passed to or returned by the call.
+	JobID string
+	// Job is the sent job, only set for Send.
+	Job *sms.Job
+}
+
+// Recorder is a fake sms.API which records every call. Sent jobs get consecutive job IDs,
+// reports and statuses have to be prepared by the test.
+// Note: To create a new instance of Recorder, use the NewRecorder function.
+type Recorder struct {
+	mu       sync.Mutex
+	calls    []Call
+	reports  map[string]*sms.Report
+	statuses map[string][]sms.SmsStatus
+	err      error
+	sent     int
+}
+
+var _ sms.API = (*Recorder)(nil)
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		reports:  map[string]*sms.Report{},
+		statuses: map[string][]sms.SmsStatus{},
+	}
+}
+
+// FailWith lets every following call return err, nil restores normal operation.
+func (r *Recorder) FailWith(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// SetReport prepares the report returned by GetReport.
+func (r *Recorder) SetReport(report sms.Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports[report.JobID] = &report
+}
+
+// SetSmsStatus prepares the statuses returned by GetSmsStatus.
+func (r *Recorder) SetSmsStatus(jobID string, statuses []sms.SmsStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[jobID] = statuses
+}
+
+// Calls returns all recorded calls in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call{}, r.calls...)
+}
+
+// Jobs returns all sent jobs in order.
+func (r *Recorder) Jobs() []sms.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := []sms.Job{}
+	for _, c := range r.calls {
+		if c.Job != nil {
+			jobs = append(jobs, *c.Job)
+		}
+	}
+	return jobs
+}
+
+func (r *Recorder) record(c Call) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, c)
+	return r.err
+}
+
+func (r *Recorder) Send(job sms.Job) (string, error) {
+	return r.SendContext(context.Background(), job)
+}
+
+func (r *Recorder) SendContext(ctx context.Context, job sms.Job) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.sent++
+	jobID := fmt.Sprintf("J%d", r.sent)
+	r.mu.Unlock()
+	if err := r.record(Call{Method: "Send", JobID: jobID, Job: &job}); err != nil {
+		return "", err
+	}
+	return jobID, nil
+}
+
+func (r *Recorder) GetReport(jobID string) (*sms.Report, error) {
+	return r.GetReportContext(context.Background(), jobID)
+}
+
+func (r *Recorder) GetReportContext(ctx context.Context, jobID string) (*sms.Report, error) {
+	if err := r.record(Call{Method: "GetReport", JobID: jobID}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if report, ok := r.reports[jobID]; ok {
+		copied := *report
+		return &copied, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *Recorder) GetSmsStatus(jobID string) (*[]sms.SmsStatus, error) {
+	return r.GetSmsStatusContext(context.Background(), jobID)
+}
+
+func (r *Recorder) GetSmsStatusContext(ctx context.Context, jobID string) (*[]sms.SmsStatus, error) {
+	if err := r.record(Call{Method: "GetSmsStatus", JobID: jobID}); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if statuses, ok := r.statuses[jobID]; ok {
+		copied := append([]sms.SmsStatus{}, statuses...)
+		return &copied, nil
+	}
+	return nil, ErrNotFound
+}
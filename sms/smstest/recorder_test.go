@@ -0,0 +1,76 @@
+package smstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/retarus/retarus-go/sms"
+)
+
+var errUnavailable = errors.New("service unavailable")
+
+func testJob() sms.Job {
+	return sms.NewJob([]sms.Message{sms.NewMessage("hello", []sms.Recipient{sms.NewRecipient("+49176000001", "", nil)})}, nil)
+}
+
+func TestRecorderSendsAndReports(t *testing.T) {
+	r := NewRecorder()
+	first, err := r.Send(testJob())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := r.SendContext(context.Background(), testJob())
+	if first != "J1" || second != "J2" {
+		t.Fatalf("expected consecutive job IDs, got %s and %s", first, second)
+	}
+	if jobs := r.Jobs(); len(jobs) != 2 || jobs[0].Messages[0].Text != "hello" {
+		t.Errorf("unexpected sent jobs %+v", jobs)
+	}
+
+	if _, err := r.GetReport(first); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound without a prepared report, got %v", err)
+	}
+	r.SetReport(sms.Report{JobID: first, Src: "Retarus"})
+	r.SetSmsStatus(first, []sms.SmsStatus{{Dst: "+49176000001", Status: "OK"}})
+	report, err := r.GetReportContext(context.Background(), first)
+	if err != nil || report.Src != "Retarus" {
+		t.Fatalf("unexpected report %+v, %v", report, err)
+	}
+	statuses, err := r.GetSmsStatus(first)
+	if err != nil || len(*statuses) != 1 || (*statuses)[0].Status != "OK" {
+		t.Fatalf("unexpected statuses %+v, %v", statuses, err)
+	}
+	(*statuses)[0].Status = "FAILED"
+	if statuses, _ := r.GetSmsStatus(first); (*statuses)[0].Status != "OK" {
+		t.Error("changing a returned status must not change the prepared one")
+	}
+}
+
+func TestRecorderRecordsCallsAndFailures(t *testing.T) {
+	r := NewRecorder()
+	r.Send(testJob())
+	r.FailWith(errUnavailable)
+	if _, err := r.GetSmsStatusContext(context.Background(), "J1"); !errors.Is(err, errUnavailable) {
+		t.Errorf("expected the configured error, got %v", err)
+	}
+	r.FailWith(nil)
+	r.GetReport("J1")
+
+	calls := r.Calls()
+	methods := []string{"Send", "GetSmsStatus", "GetReport"}
+	if len(calls) != len(methods) {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+	for i, m := range methods {
+		if calls[i].Method != m || calls[i].JobID != "J1" {
+			t.Errorf("call %d: expected %s of J1, got %+v", i, m, calls[i])
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.SendContext(ctx, testJob()); !errors.Is(err, context.Canceled) || len(r.Calls()) != len(methods) {
+		t.Errorf("expected a cancelled send not to be recorded, got %v", err)
+	}
+}
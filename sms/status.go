@@ -1,25 +1,35 @@
 package sms
 
+import "github.com/retarus/retarus-go/common"
+
 // SmsStatus represents the status of an SMS.
 // It includes fields like smsId, destination, process status, etc.
 type SmsStatus struct {
-	smsId         string `json:"smsId"`
-	dst           string `json:"dst"`
-	processStatus string `json:"processStatus"`
-	status        string `json:"status"`
-	customerRef   string `json:"customerRef"`
-	reason        string `json:"reason"`
-	sentTs        string `json:"sentTs"`
-	finishedTs    string `json:"finishedTs"`
+	// SmsID (required) is the ID of the single SMS.
+	SmsID string `json:"smsId"`
+	// Dst (required) is the mobile phone number of the recipient.
+	Dst string `json:"dst"`
+	// ProcessStatus (required) is QUEUED, DISPATCHED or FINISHED.
+	ProcessStatus string `json:"processStatus"`
+	// Status (required) is the delivery status, e.g. OK or FAILED.
+	Status string `json:"status"`
+	// CustomerRef (optional) is the reference of the recipient.
+	CustomerRef string `json:"customerRef,omitempty"`
+	// Reason (optional) explains the status.
+	Reason string `json:"reason,omitempty"`
+	// SentTS (optional) is the time the SMS was sent to the network.
+	SentTS *common.ISO8601Time `json:"sentTs,omitempty"`
+	// FinishedTS (optional) is the time the delivery finished.
+	FinishedTS *common.ISO8601Time `json:"finishedTs,omitempty"`
 }
 
 func (s SmsStatus) IsZero() bool {
-	return s.smsId == "" &&
-		s.dst == "" &&
-		s.processStatus == "" &&
-		s.status == "" &&
-		s.customerRef == "" &&
-		s.reason == "" &&
-		s.sentTs == "" &&
-		s.finishedTs == ""
+	return s.SmsID == "" &&
+		s.Dst == "" &&
+		s.ProcessStatus == "" &&
+		s.Status == "" &&
+		s.CustomerRef == "" &&
+		s.Reason == "" &&
+		s.SentTS == nil &&
+		s.FinishedTS == nil
 }
@@ -0,0 +1,227 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+var (
+	// ErrMissingVariable is returned if the data of a recipient lacks a variable of the template.
+	ErrMissingVariable = errors.New("missing template variable")
+	// ErrTooManyParts is returned if a rendered message has more parts than Options.MaxParts.
+	ErrTooManyParts = errors.New("message has too many parts")
+)
+
+// TemplateRecipient is a recipient with the data its message is rendered with, a map with string
+// keys or a struct.
+type TemplateRecipient struct {
+	Recipient
+	Data any
+}
+
+// RenderError is the error of rendering the message of a single recipient.
+type RenderError struct {
+	// Index is the position of the recipient in the rendered list.
+	Index int
+	// Recipient is the recipient whose message failed.
+	Recipient Recipient
+	// Missing are the template variables the data of the recipient lacks.
+	Missing []string
+	// Err is ErrMissingVariable, ErrTooManyParts or the error of the template.
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	if len(e.Missing) > 0 {
+		return fmt.Sprintf("recipient %d (%s): %v: %s", e.Index, e.Recipient.Dst, e.Err, strings.Join(e.Missing, ", "))
+	}
+	return fmt.Sprintf("recipient %d (%s): %v", e.Index, e.Recipient.Dst, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// Template renders a personalized message per recipient with text/template, e.g.
+// "Dear {{.firstname}}, your parcel arrives today." Variables are the top-level fields of the
+// recipient's data.
+// Note: To create a new instance of Template, use the NewTemplate function.
+type Template struct {
+	tmpl      *template.Template
+	variables []string
+}
+
+// NewTemplate parses the template text.
+func NewTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("sms").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl, variables: variables(tmpl.Tree.Root)}, nil
+}
+
+// Variables returns the names of the variables the template uses, sorted.
+func (t *Template) Variables() []string {
+	return append([]string(nil), t.variables...)
+}
+
+// Render renders the message for data.
+func (t *Template) Render(data any) (string, error) {
+	text, missing, err := t.render(data)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", err, strings.Join(missing, ", "))
+	}
+	return text, err
+}
+
+// render returns the missing variables with ErrMissingVariable before executing the template.
+func (t *Template) render(data any) (string, []string, error) {
+	if missing := t.missing(data); len(missing) > 0 {
+		return "", missing, ErrMissingVariable
+	}
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", nil, err
+	}
+	return b.String(), nil, nil
+}
+
+// Messages renders the message of every recipient and groups the recipients with the same text
+// into one Message, in the order of their first recipient. The parts of every message are checked
+// against the Encoding and MaxParts of options, which may be nil. If any recipient fails, no
+// messages are returned and the error joins a *RenderError per failed recipient.
+func (t *Template) Messages(recipients []TemplateRecipient, options *Options) ([]Message, error) {
+	encoding, maxParts := STANDARD, 0
+	if options != nil {
+		encoding, maxParts = options.Encoding, options.MaxParts
+	}
+	var messages []Message
+	index := map[string]int{}
+	var errs []error
+	for i, r := range recipients {
+		text, missing, err := t.render(r.Data)
+		if err == nil && maxParts > 0 && Parts(text, encoding) > maxParts {
+			err = fmt.Errorf("%w: %d of at most %d", ErrTooManyParts, Parts(text, encoding), maxParts)
+		}
+		if err != nil {
+			errs = append(errs, &RenderError{Index: i, Recipient: r.Recipient, Missing: missing, Err: err})
+			continue
+		}
+		if m, ok := index[text]; ok {
+			messages[m].Recipients = append(messages[m].Recipients, r.Recipient)
+			continue
+		}
+		index[text] = len(messages)
+		messages = append(messages, NewMessage(text, []Recipient{r.Recipient}))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return messages, nil
+}
+
+// Job renders the messages of the recipients like Messages and returns them as a job with options.
+func (t *Template) Job(recipients []TemplateRecipient, options *Options) (Job, error) {
+	messages, err := t.Messages(recipients, options)
+	if err != nil {
+		return Job{}, err
+	}
+	return NewJob(messages, options), nil
+}
+
+// missing returns the variables of the template which data doesn't have.
+func (t *Template) missing(data any) []string {
+	v := reflect.ValueOf(data)
+	var missing []string
+	for _, name := range t.variables {
+		if !hasVariable(v, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// hasVariable reports whether text/template can evaluate the field name of v.
+func hasVariable(v reflect.Value, name string) bool {
+	for v.IsValid() {
+		if _, ok := v.Type().MethodByName(name); ok {
+			return true
+		}
+		if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+			break
+		}
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		return v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())).IsValid()
+	case reflect.Struct:
+		f, ok := v.Type().FieldByName(name)
+		return ok && f.IsExported()
+	}
+	return false
+}
+
+// variables returns the sorted names of the top-level fields the template refers to, like
+// firstname in {{.firstname}} or {{if .firstname}}.
+func variables(root parse.Node) []string {
+	names := map[string]bool{}
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			names[n.Ident[0]] = true
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			// fields inside with and range refer to the pipeline, not to the recipient's data
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	walk(root)
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
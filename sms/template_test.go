@@ -0,0 +1,82 @@
+package sms
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type customer struct {
+	FirstName string
+	Parcel    string
+}
+
+func (c customer) Greeting() string {
+	return "Dear " + c.FirstName
+}
+
+func TestTemplateGroupsRecipients(t *testing.T) {
+	tmpl, err := NewTemplate("Hello {{.firstname}}, your parcel arrives {{if .evening}}tonight{{else}}today{{end}}.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars := tmpl.Variables(); !reflect.DeepEqual(vars, []string{"evening", "firstname"}) {
+		t.Errorf("unexpected variables %v", vars)
+	}
+	recipients := []TemplateRecipient{
+		{Recipient{Dst: "+4917600000001"}, map[string]any{"firstname": "Axel", "evening": false}},
+		{Recipient{Dst: "+4917600000002"}, map[string]any{"firstname": "Berta", "evening": true}},
+		{Recipient{Dst: "+4917600000003"}, map[string]any{"firstname": "Axel", "evening": false}},
+	}
+	messages, err := tmpl.Messages(recipients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 distinct messages, got %v", messages)
+	}
+	if messages[0].Text != "Hello Axel, your parcel arrives today." || len(messages[0].Recipients) != 2 || messages[0].Recipients[1].Dst != "+4917600000003" {
+		t.Errorf("unexpected first message %+v", messages[0])
+	}
+	if messages[1].Text != "Hello Berta, your parcel arrives tonight." || len(messages[1].Recipients) != 1 {
+		t.Errorf("unexpected second message %+v", messages[1])
+	}
+}
+
+func TestTemplateWithStruct(t *testing.T) {
+	tmpl, err := NewTemplate("{{.Greeting}}, parcel {{.Parcel}} is on its way.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := tmpl.Render(&customer{FirstName: "Axel", Parcel: "4711"})
+	if err != nil || text != "Dear Axel, parcel 4711 is on its way." {
+		t.Errorf("unexpected rendering %q %v", text, err)
+	}
+	if _, err := tmpl.Render(map[string]string{"Parcel": "4711"}); !errors.Is(err, ErrMissingVariable) || !strings.Contains(err.Error(), "Greeting") {
+		t.Errorf("expected the missing Greeting, got %v", err)
+	}
+}
+
+func TestTemplateReportsAllFailedRecipients(t *testing.T) {
+	tmpl, err := NewTemplate("Hello {{.firstname}} {{.lastname}}! {{.text}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := []TemplateRecipient{
+		{Recipient{Dst: "+4917600000001"}, map[string]string{"firstname": "Axel", "lastname": "Moll", "text": "Hi"}},
+		{Recipient{Dst: "+4917600000002"}, map[string]string{"firstname": "Berta"}},
+		{Recipient{Dst: "+4917600000003"}, map[string]string{"firstname": "Carl", "lastname": "Kath", "text": strings.Repeat("x", 400)}},
+	}
+	messages, err := tmpl.Messages(recipients, &Options{MaxParts: 2})
+	if messages != nil {
+		t.Errorf("no messages should be returned if a recipient fails, got %v", messages)
+	}
+	if !errors.Is(err, ErrMissingVariable) || !errors.Is(err, ErrTooManyParts) {
+		t.Fatalf("expected a missing variable and too many parts, got %v", err)
+	}
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) || renderErr.Index != 1 || !reflect.DeepEqual(renderErr.Missing, []string{"lastname", "text"}) {
+		t.Errorf("unexpected first error %+v", renderErr)
+	}
+}